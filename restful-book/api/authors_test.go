@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAuthors(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	var author struct{ ID string }
+	data(t, expect(t, h, http.StatusCreated, "POST", "/api/authors", `{"name":"J. R. R. Tolkien","bio":"x"}`), &author)
+	aid := author.ID
+	expect(t, h, http.StatusConflict, "POST", "/api/authors", `{"name":"J. R. R. Tolkien"}`)
+	expect(t, h, http.StatusUnprocessableEntity, "POST", "/api/authors", `{"name":""}`)
+
+	bid := create(t, h, `{"title":"The Hobbit","author":"JRR Tolkien","author_ids":["`+aid+`"]}`)
+	expect(t, h, http.StatusUnprocessableEntity, "POST", "/api/books",
+		`{"title":"X","author":"Y","author_ids":["00000000-0000-0000-0000-000000000000"]}`)
+	expect(t, h, http.StatusUnprocessableEntity, "POST", "/api/books", `{"title":"X","author":"Y","author_ids":["nope"]}`)
+	expect(t, h, http.StatusUnprocessableEntity, "PATCH", "/api/books/"+bid,
+		`{"author_ids":["00000000-0000-0000-0000-000000000000"]}`, "Content-Type", mergePatch)
+	expect(t, h, http.StatusBadRequest, "PATCH", "/api/books/"+bid, `{"author_ids":"`+aid+`"}`, "Content-Type", mergePatch)
+
+	if rec := expect(t, h, http.StatusOK, "GET", "/api/authors/"+aid+"/books", ""); !strings.Contains(rec.Body.String(), bid) {
+		t.Fatalf("author's books: %s", rec.Body)
+	}
+	if rec := expect(t, h, http.StatusOK, "GET", "/api/books?author_id="+aid, ""); !strings.Contains(rec.Body.String(), bid) {
+		t.Fatalf("author_id filter: %s", rec.Body)
+	}
+
+	// Trashed books still hold on to their authors.
+	expect(t, h, http.StatusConflict, "DELETE", "/api/authors/"+aid, "")
+	expect(t, h, http.StatusOK, "DELETE", "/api/books/"+bid, "")
+	expect(t, h, http.StatusConflict, "DELETE", "/api/authors/"+aid, "")
+	expect(t, h, http.StatusOK, "POST", "/api/books/"+bid+"/restore", "")
+	expect(t, h, http.StatusOK, "PATCH", "/api/books/"+bid, `{"author_ids":null}`, "Content-Type", mergePatch)
+	if rec := expect(t, h, http.StatusOK, "GET", "/api/authors/"+aid+"/books", ""); strings.Contains(rec.Body.String(), bid) {
+		t.Fatalf("unlinked book still listed: %s", rec.Body)
+	}
+
+	expect(t, h, http.StatusForbidden, "PUT", "/api/authors/"+aid, `{"name":"Tolkien"}`, "X-API-Key", "k2")
+	expect(t, h, http.StatusOK, "PUT", "/api/authors/"+aid, `{"name":"Tolkien"}`)
+	expect(t, h, http.StatusOK, "DELETE", "/api/authors/"+aid, "")
+	expect(t, h, http.StatusNotFound, "GET", "/api/authors/"+aid+"/books", "")
+}
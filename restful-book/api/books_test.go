@@ -0,0 +1,353 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/api/middleware"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+)
+
+const mergePatch = "application/merge-patch+json"
+
+func TestListBooks(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	expect(t, h, http.StatusNotFound, "GET", "/api/books", "")
+	create(t, h, `{"title":"The Go Programming Language","author":"Donovan","published_year":2015}`)
+	create(t, h, `{"title":"B","author":"Donovan","published_year":2010}`)
+	create(t, h, `{"title":"C","author":"Kernighan","published_year":1978}`)
+
+	rec := expect(t, h, http.StatusOK, "GET", "/api/books?author=Donovan&sort=published_year&limit=1", "")
+	var books []models.Book
+	data(t, rec, &books)
+	if len(books) != 1 || books[0].Title != "B" || !strings.Contains(rec.Body.String(), `"meta":{"total":2,"limit":1,"offset":0}`) {
+		t.Fatalf("filtered page: %s", rec.Body)
+	}
+	expect(t, h, http.StatusBadRequest, "GET", "/api/books?sort=bogus", "")
+	expect(t, h, http.StatusBadRequest, "GET", "/api/books?limit=-1", "")
+	expect(t, h, http.StatusBadRequest, "GET", "/api/books?year_from=2000&year_to=1990", "")
+}
+
+func TestSearchBooks(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	golang := create(t, h, `{"title":"The Go Programming Language","author":"Alan Donovan","description":"go book"}`)
+	create(t, h, `{"title":"Programming Pearls","author":"Jon Bentley","description":"classic about algorithms"}`)
+
+	for query, want := range map[string]int{"go+programming": 1, "prog": 2, "DONOV": 1, "pascal": 0} {
+		var books []models.Book
+		data(t, expect(t, h, http.StatusOK, "GET", "/api/books/search?q="+query, ""), &books)
+		if len(books) != want {
+			t.Errorf("q=%s: %d books, want %d", query, len(books), want)
+		}
+		if query == "go+programming" && len(books) == 1 && books[0].ID != golang {
+			t.Errorf("q=%s found %s", query, books[0].Title)
+		}
+	}
+	expect(t, h, http.StatusBadRequest, "GET", "/api/books/search", "")
+	expect(t, h, http.StatusBadRequest, "GET", "/api/books/search?q=go&limit=x", "")
+}
+
+func TestValidation(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	rec := expect(t, h, http.StatusUnprocessableEntity, "POST", "/api/books",
+		`{"title":"","author":"A","published_year":-5,"isbn":"978-0134190441"}`)
+	for _, code := range []string{"empty_title", "invalid_year", "invalid_isbn"} {
+		if !strings.Contains(rec.Body.String(), `"code":"`+code+`"`) {
+			t.Errorf("field error %s missing: %s", code, rec.Body)
+		}
+	}
+	create(t, h, `{"title":"x","author":"A","published_year":2015,"isbn":"0-306-40615-2"}`)
+	create(t, h, `{"title":"y","author":"A","isbn":"080442957X"}`)
+	expect(t, h, http.StatusConflict, "POST", "/api/books", `{"title":"x","author":"A"}`)
+	expect(t, h, http.StatusUnprocessableEntity, "GET", "/api/books/abc", "")
+	expect(t, h, http.StatusUnprocessableEntity, "PUT", "/api/books/abc", `{"title":"x","author":"A"}`)
+}
+
+func TestPatchAndETags(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	id := create(t, h, `{"title":"T","author":"A","published_year":2000,"description":"old"}`)
+	path := "/api/books/" + id
+
+	rec := expect(t, h, http.StatusOK, "GET", path, "")
+	if tag := rec.Header().Get("ETag"); tag != `"1"` {
+		t.Fatalf("ETag = %q", tag)
+	}
+	expect(t, h, http.StatusNotModified, "GET", path, "", "If-None-Match", `"1"`)
+
+	rec = expect(t, h, http.StatusOK, "PATCH", path, `{"description":"new","isbn":null}`, "Content-Type", mergePatch)
+	var book models.Book
+	data(t, rec, &book)
+	if book.Description != "new" || book.Title != "T" || book.PublishedYear != 2000 || book.Version != 2 {
+		t.Fatalf("patched book: %+v", book)
+	}
+	if rec := expect(t, h, http.StatusOK, "GET", "/api/books/search?q=new", ""); !strings.Contains(rec.Body.String(), id) {
+		t.Fatalf("patched book not found by its new description: %s", rec.Body)
+	}
+	expect(t, h, http.StatusUnprocessableEntity, "PATCH", path, `{"title":""}`, "Content-Type", mergePatch)
+	expect(t, h, http.StatusBadRequest, "PATCH", path, `{"title":`, "Content-Type", mergePatch)
+	rec = expect(t, h, http.StatusUnsupportedMediaType, "PATCH", path, `{}`, "Content-Type", "text/plain")
+	if rec.Header().Get("Accept-Patch") != mergePatch {
+		t.Fatalf("Accept-Patch = %q", rec.Header().Get("Accept-Patch"))
+	}
+
+	expect(t, h, http.StatusPreconditionFailed, "PUT", path, `{"title":"T2","author":"A"}`, "If-Match", `"1"`)
+	expect(t, h, http.StatusOK, "PUT", path, `{"title":"T2","author":"A"}`, "If-Match", `"2"`)
+	expect(t, h, http.StatusPreconditionFailed, "DELETE", path, "", "If-Match", `"2"`)
+	expect(t, h, http.StatusOK, "DELETE", path, "", "If-Match", `"3"`)
+}
+
+func TestTrash(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	id := create(t, h, `{"title":"T","author":"A"}`)
+	create(t, h, `{"title":"U","author":"A"}`)
+
+	expect(t, h, http.StatusOK, "DELETE", "/api/books/"+id, "")
+	expect(t, h, http.StatusNotFound, "GET", "/api/books/"+id, "")
+	if rec := expect(t, h, http.StatusOK, "GET", "/api/books/search?q=T", ""); strings.Contains(rec.Body.String(), id) {
+		t.Fatal("trashed book found by search")
+	}
+	if rec := expect(t, h, http.StatusOK, "GET", "/api/books/trash", ""); !strings.Contains(rec.Body.String(), `"deleted_at"`) {
+		t.Fatalf("trash: %s", rec.Body)
+	}
+	// Only the owner and admins see a trashed book.
+	expect(t, h, http.StatusUnauthorized, "GET", "/api/books/trash", "", "X-API-Key", "")
+	if rec := expect(t, h, http.StatusOK, "GET", "/api/books/trash", "", "X-API-Key", "k2"); strings.Contains(rec.Body.String(), id) {
+		t.Fatalf("trash as bob: %s", rec.Body)
+	}
+	if rec := expect(t, h, http.StatusOK, "GET", "/api/books/trash", "", "X-API-Key", "k3"); !strings.Contains(rec.Body.String(), id) {
+		t.Fatalf("trash as admin: %s", rec.Body)
+	}
+
+	expect(t, h, http.StatusForbidden, "POST", "/api/books/"+id+"/restore", "", "X-API-Key", "k2")
+	rec := expect(t, h, http.StatusOK, "POST", "/api/books/"+id+"/restore", "")
+	if tag := rec.Header().Get("ETag"); tag != `"3"` {
+		t.Fatalf("restored ETag = %q", tag)
+	}
+	expect(t, h, http.StatusNotFound, "POST", "/api/books/"+id+"/restore", "")
+	if rec := expect(t, h, http.StatusOK, "GET", "/api/books/search?q=T", ""); !strings.Contains(rec.Body.String(), id) {
+		t.Fatal("restored book missing from search")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	id := create(t, h, `{"title":"T","author":"A","description":"one"}`)
+	path := "/api/books/" + id
+	expect(t, h, http.StatusOK, "PATCH", path, `{"description":"two"}`, "Content-Type", mergePatch)
+	expect(t, h, http.StatusOK, "PUT", path, `{"title":"T2","author":"A","description":"three"}`,
+		"X-API-Key", "k3", "X-Request-ID", "req-put")
+
+	expect(t, h, http.StatusPreconditionFailed, "POST", path+"/revert", `{"revision":1}`, "If-Match", `"2"`)
+	expect(t, h, http.StatusNotFound, "POST", path+"/revert", `{"revision":9}`)
+	expect(t, h, http.StatusForbidden, "POST", path+"/revert", `{"revision":1}`, "X-API-Key", "k2")
+	rec := expect(t, h, http.StatusOK, "POST", path+"/revert", `{"revision":1}`, "If-Match", `"3"`)
+	if !strings.Contains(rec.Body.String(), `"description":"one"`) || rec.Header().Get("ETag") != `"4"` {
+		t.Fatalf("revert: %s", rec.Body)
+	}
+	expect(t, h, http.StatusOK, "DELETE", path, "")
+	expect(t, h, http.StatusOK, "POST", path+"/restore", "")
+
+	var revisions []models.Revision
+	data(t, expect(t, h, http.StatusOK, "GET", path+"/history", ""), &revisions)
+	var actions []string
+	for _, r := range revisions {
+		actions = append(actions, r.Action)
+	}
+	if got := strings.Join(actions, ","); got != "created,updated,updated,reverted,deleted,restored" {
+		t.Fatalf("actions = %s", got)
+	}
+	if revisions[2].Actor != "root" || revisions[2].RequestID != "req-put" {
+		t.Fatalf("revision 3: %+v", revisions[2])
+	}
+	expect(t, h, http.StatusUnauthorized, "GET", path+"/history", "", "X-API-Key", "")
+	expect(t, h, http.StatusForbidden, "GET", path+"/history", "", "X-API-Key", "k2")
+	expect(t, h, http.StatusOK, "GET", path+"/history", "", "X-API-Key", "k3")
+	expect(t, h, http.StatusNotFound, "GET", "/api/books/00000000-0000-0000-0000-000000000000/history", "")
+}
+
+func TestBulk(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	if rec := expect(t, h, http.StatusOK, "GET", "/api/books/export?format=csv", ""); rec.Body.String() != "id,title,author,published_year,isbn,description,author_ids,version\n" {
+		t.Fatalf("empty export: %q", rec.Body)
+	}
+
+	var summary struct{ Created, Duplicate, Invalid int }
+	data(t, expect(t, h, http.StatusOK, "POST", "/api/books/bulk",
+		`[{"title":"A","author":"X"},{"title":"A","author":"X"},{"title":"","author":"X"},{"title":5}]`,
+		"Content-Type", "application/json"), &summary)
+	if summary.Created != 1 || summary.Duplicate != 1 || summary.Invalid != 2 {
+		t.Fatalf("JSON import: %+v", summary)
+	}
+	data(t, expect(t, h, http.StatusOK, "POST", "/api/books/bulk", "{\"title\":\"B\",\"author\":\"X\"}\n\nnot json\n",
+		"Content-Type", "application/x-ndjson"), &summary)
+	if summary.Created != 1 || summary.Invalid != 1 {
+		t.Fatalf("NDJSON import: %+v", summary)
+	}
+	data(t, expect(t, h, http.StatusOK, "POST", "/api/books/bulk", "title,author,published_year\nC,Y,1999\nD,Y,abc\n",
+		"Content-Type", "text/csv"), &summary)
+	if summary.Created != 1 || summary.Invalid != 1 {
+		t.Fatalf("CSV import: %+v", summary)
+	}
+	expect(t, h, http.StatusUnsupportedMediaType, "POST", "/api/books/bulk", "x", "Content-Type", "text/plain")
+
+	// Linked books need no author text, and are told apart by their author
+	// IDs rather than by it.
+	var first, second models.Author
+	data(t, expect(t, h, http.StatusCreated, "POST", "/api/authors", `{"name":"First"}`), &first)
+	data(t, expect(t, h, http.StatusCreated, "POST", "/api/authors", `{"name":"Second"}`), &second)
+	csv := "title,author_ids\n" +
+		"E," + first.ID + ";" + second.ID + "\n" +
+		"E," + second.ID + "\n" +
+		"E," + second.ID + " ; " + first.ID + "\n" +
+		"F,\n"
+	data(t, expect(t, h, http.StatusOK, "POST", "/api/books/bulk", csv, "Content-Type", "text/csv"), &summary)
+	if summary.Created != 2 || summary.Duplicate != 1 || summary.Invalid != 1 {
+		t.Fatalf("CSV import with author IDs: %+v", summary)
+	}
+
+	rec := expect(t, h, http.StatusOK, "GET", "/api/books/export?format=csv", "")
+	if lines := strings.Count(rec.Body.String(), "\n"); lines != 6 || !strings.Contains(rec.Body.String(), ",C,Y,1999,,,,1\n") ||
+		!strings.Contains(rec.Body.String(), ",E,,0,,,"+first.ID+";"+second.ID+",1\n") {
+		t.Fatalf("CSV export: %s", rec.Body)
+	}
+	rec = expect(t, h, http.StatusOK, "GET", "/api/books/export", "")
+	if rec.Header().Get("Content-Type") != "application/x-ndjson" || strings.Count(rec.Body.String(), "\n") != 5 {
+		t.Fatalf("NDJSON export: %s", rec.Body)
+	}
+	expect(t, h, http.StatusBadRequest, "GET", "/api/books/export?format=xml", "")
+}
+
+// stallingWriter waits out the server's write timeout before its first write.
+type stallingWriter struct {
+	http.ResponseWriter
+	stall time.Duration
+	once  sync.Once
+}
+
+func (s *stallingWriter) Write(p []byte) (int, error) {
+	s.once.Do(func() { time.Sleep(s.stall) })
+	return s.ResponseWriter.Write(p)
+}
+
+func (s *stallingWriter) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+func TestBulkOutlivesWriteTimeout(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	const timeout = 50 * time.Millisecond
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(&stallingWriter{ResponseWriter: w, stall: 2 * timeout}, r)
+	}))
+	srv.Config.WriteTimeout = timeout
+	srv.Start()
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		method, path, body, want string
+	}{
+		{"POST", "/api/books/bulk", `[{"title":"A","author":"X"}]`, `"created":1`},
+		{"GET", "/api/books/export", "", `"title":"A"`},
+	} {
+		req, err := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", "k1")
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("%s %s past the write timeout: %v", tc.method, tc.path, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || !strings.Contains(string(body), tc.want) {
+			t.Fatalf("%s %s past the write timeout = %q, %v", tc.method, tc.path, body, err)
+		}
+	}
+}
+
+func TestNegotiation(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	id := create(t, h, `{"title":"T","author":"A","published_year":2000}`)
+
+	rec := expect(t, h, http.StatusOK, "GET", "/api/books", "", "Accept", "application/xml")
+	if rec.Header().Get("Content-Type") != "application/xml" || !strings.Contains(rec.Body.String(), "<title>T</title>") {
+		t.Fatalf("XML list: %s", rec.Body)
+	}
+	rec = expect(t, h, http.StatusOK, "GET", "/api/books/"+id, "", "Accept", "text/html;q=0.9, application/msgpack")
+	var v struct {
+		Data models.Book `msgpack:"data"`
+	}
+	dec := msgpack.NewDecoder(rec.Body)
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(&v); err != nil || v.Data.ID != id {
+		t.Fatalf("MessagePack book: %+v, %v", v.Data, err)
+	}
+	expect(t, h, http.StatusNotAcceptable, "GET", "/api/books", "", "Accept", "text/html")
+	for accept, want := range map[string]string{
+		"application/*;q=0.5, application/json;q=0": "application/xml",
+		"text/*": "application/xml",
+	} {
+		if rec := expect(t, h, http.StatusOK, "GET", "/api/books/"+id, "", "Accept", accept); rec.Header().Get("Content-Type") != want {
+			t.Errorf("Accept %s: Content-Type %q, want %q", accept, rec.Header().Get("Content-Type"), want)
+		}
+	}
+	expect(t, h, http.StatusOK, "GET", "/api/books/export?format=csv", "", "Accept", "text/csv")
+
+	expect(t, h, http.StatusUnsupportedMediaType, "POST", "/api/books", `title=x`, "Content-Type", "application/x-www-form-urlencoded")
+	rec = expect(t, h, http.StatusCreated, "POST", "/api/books",
+		`<book><title>X</title><author>Y</author><published_year>1999</published_year></book>`,
+		"Content-Type", "application/xml", "Accept", "application/xml")
+	if !strings.Contains(rec.Body.String(), "<published_year>1999</published_year>") {
+		t.Fatalf("XML create: %s", rec.Body)
+	}
+	for _, doc := range []string{
+		`<book><title>X</title><author>Y</author><bogus>1</bogus></book>`,
+		`<book><title>X</title><cover><url>u</url></cover></book>`,
+		`<book><title>X</title><author>Y</author></book><book></book>`,
+		`<book><title>X</title><author>Y</author></book>trailing`,
+		`<book><title>X</title>`,
+	} {
+		expect(t, h, http.StatusBadRequest, "POST", "/api/books", doc, "Content-Type", "application/xml")
+	}
+	expect(t, h, http.StatusCreated, "POST", "/api/books",
+		"<?xml version=\"1.0\"?>\n<!-- c -->\n<book><title>Z</title><author>Y</author></book>\n",
+		"Content-Type", "application/xml")
+	body, _ := msgpack.Marshal(map[string]any{"title": "M", "author": "P", "published_year": 2001})
+	rec = expect(t, h, http.StatusCreated, "POST", "/api/books", string(body), "Content-Type", "application/msgpack")
+	if !strings.Contains(rec.Body.String(), `"published_year":2001`) {
+		t.Fatalf("MessagePack create: %s", rec.Body)
+	}
+	rec = expect(t, h, http.StatusUnprocessableEntity, "POST", "/api/books", `{"title":""}`, "Accept", "application/xml")
+	if !strings.Contains(rec.Body.String(), "<code>validation_failed</code>") {
+		t.Fatalf("XML error: %s", rec.Body)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	h := newTestRouter(t, Options{
+		MaxBodyBytes: 100,
+		RateLimit:    middleware.RateLimitConfig{PerSecond: 1, Burst: 8},
+	})
+	expect(t, h, http.StatusBadRequest, "POST", "/api/books", `{"title":"T","author":"A","bogus":1}`)
+	expect(t, h, http.StatusBadRequest, "POST", "/api/books", `{"title":"T","author":"A"} {}`)
+	expect(t, h, http.StatusRequestEntityTooLarge, "POST", "/api/books", `{"title":"`+strings.Repeat("x", 200)+`","author":"A"}`)
+
+	id := create(t, h, `{"title":"T","author":"A"}`)
+	expect(t, h, http.StatusBadRequest, "PATCH", "/api/books/"+id, `{"bogus":1}`, "Content-Type", mergePatch)
+	expect(t, h, http.StatusRequestEntityTooLarge, "PATCH", "/api/books/"+id,
+		`{"description":"`+strings.Repeat("x", 200)+`"}`, "Content-Type", mergePatch)
+
+	for range 4 {
+		do(t, h, "GET", "/api/books", "")
+	}
+	rec := expect(t, h, http.StatusTooManyRequests, "GET", "/api/books", "")
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("429 without Retry-After")
+	}
+}
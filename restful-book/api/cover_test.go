@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// coverForm returns a multipart body with data in field and its
+// Content-Type.
+func coverForm(t *testing.T, field string, data []byte) (string, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile(field, "cover.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+	return buf.String(), mw.FormDataContentType()
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCover(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	id := create(t, h, `{"title":"T","author":"A"}`)
+	path := "/api/books/" + id + "/cover"
+	img := testPNG(t, 600, 300)
+
+	expect(t, h, http.StatusNotFound, "GET", path, "")
+	body, ctype := coverForm(t, "cover", img)
+	expect(t, h, http.StatusForbidden, "PUT", path, body, "Content-Type", ctype, "X-API-Key", "k2")
+	rec := expect(t, h, http.StatusOK, "PUT", path, body, "Content-Type", ctype)
+	if !strings.Contains(rec.Body.String(), `"width":600`) {
+		t.Fatalf("cover metadata: %s", rec.Body)
+	}
+
+	rec = expect(t, h, http.StatusOK, "GET", path, "", "Accept", "image/png")
+	if !bytes.Equal(rec.Body.Bytes(), img) || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("cover: %d bytes of %s", rec.Body.Len(), rec.Header().Get("Content-Type"))
+	}
+	expect(t, h, http.StatusNotModified, "GET", path, "", "If-None-Match", rec.Header().Get("ETag"))
+	rec = expect(t, h, http.StatusOK, "GET", path+"/thumbnail", "")
+	thumb, err := png.Decode(rec.Body)
+	if err != nil || thumb.Bounds().Dx() != 256 || thumb.Bounds().Dy() != 128 {
+		t.Fatalf("thumbnail: %v %v", thumb.Bounds(), err)
+	}
+
+	body, ctype = coverForm(t, "cover", []byte("hello"))
+	expect(t, h, http.StatusUnsupportedMediaType, "PUT", path, body, "Content-Type", ctype)
+	expect(t, h, http.StatusUnsupportedMediaType, "PUT", path, `{}`)
+	body, ctype = coverForm(t, "cover", make([]byte, 6<<20))
+	expect(t, h, http.StatusRequestEntityTooLarge, "PUT", path, body, "Content-Type", ctype)
+	body, ctype = coverForm(t, "image", img)
+	expect(t, h, http.StatusBadRequest, "PUT", path, body, "Content-Type", ctype)
+
+	// The cover is managed by the server, not by book bodies.
+	if rec := expect(t, h, http.StatusOK, "PUT", "/api/books/"+id, `{"title":"T2","author":"A"}`); !strings.Contains(rec.Body.String(), `"sha256"`) {
+		t.Fatalf("cover lost on update: %s", rec.Body)
+	}
+	if rec := expect(t, h, http.StatusOK, "PATCH", "/api/books/"+id, `{"cover":null}`, "Content-Type", mergePatch); !strings.Contains(rec.Body.String(), `"sha256"`) {
+		t.Fatalf("cover removed by patch: %s", rec.Body)
+	}
+}
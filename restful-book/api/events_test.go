@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/stream"
+)
+
+// eventStream reads Server-Sent Events messages from GET /api/books/events.
+type eventStream struct {
+	r    *bufio.Reader
+	body io.Closer
+}
+
+func openEvents(t *testing.T, srv *httptest.Server, lastEventID string) *eventStream {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/books/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		resp.Body.Close()
+		t.Fatalf("event stream: %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return &eventStream{r: bufio.NewReader(resp.Body), body: resp.Body}
+}
+
+// next returns the fields of the next message other than the retry
+// preamble, joined by "|", or "EOF" once the stream ends.
+func (s *eventStream) next() string {
+	var fields []string
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return "EOF"
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line != "" {
+			fields = append(fields, line)
+			continue
+		}
+		if len(fields) > 0 && !strings.HasPrefix(fields[0], "retry:") {
+			return strings.Join(fields, "|")
+		}
+		fields = nil
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	hub := stream.NewHub(2)
+	h := newTestRouter(t, Options{Stream: hub})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	live := openEvents(t, srv, "")
+	waitFor(t, func() bool { return hub.Subscribers() == 1 })
+	id := create(t, h, `{"title":"T","author":"A"}`)
+	first := live.next()
+	if !strings.Contains(first, "-1|event: book.created|data: {") {
+		t.Fatalf("first event: %s", first)
+	}
+	epoch, _, _ := strings.Cut(strings.TrimPrefix(first, "id: "), "-")
+	expect(t, h, http.StatusOK, "PUT", "/api/books/"+id, `{"title":"T2","author":"A"}`)
+	if m := live.next(); !strings.HasPrefix(m, "id: "+epoch+"-2|event: book.updated") {
+		t.Fatalf("second event: %s", m)
+	}
+	live.body.Close()
+	expect(t, h, http.StatusOK, "DELETE", "/api/books/"+id, "")
+
+	resumed := openEvents(t, srv, epoch+"-2")
+	if m := resumed.next(); !strings.HasPrefix(m, "id: "+epoch+"-3|event: book.deleted") {
+		t.Fatalf("resumed stream: %s", m)
+	}
+	resumed.body.Close()
+
+	// The hub only keeps two events, so event 1 is gone.
+	behind := openEvents(t, srv, epoch+"-0")
+	if m := behind.next(); !strings.HasPrefix(m, "event: resync") {
+		t.Fatalf("want resync, got %s", m)
+	}
+	for _, want := range []string{"id: " + epoch + "-2|", "id: " + epoch + "-3|"} {
+		if m := behind.next(); !strings.HasPrefix(m, want) {
+			t.Fatalf("replay after resync: %s, want %s", m, want)
+		}
+	}
+
+	// Sequence numbers restart with the server: an ID from the previous
+	// process gets a resync and everything buffered, not just what follows
+	// its sequence number.
+	restarted := openEvents(t, srv, "0123456789abcdef-2")
+	for _, want := range []string{"event: resync", "id: " + epoch + "-2|", "id: " + epoch + "-3|"} {
+		if m := restarted.next(); !strings.HasPrefix(m, want) {
+			t.Fatalf("stale epoch: %s, want %s", m, want)
+		}
+	}
+	restarted.body.Close()
+
+	expect(t, h, http.StatusBadRequest, "GET", "/api/books/events", "", "Last-Event-ID", "x")
+	expect(t, h, http.StatusBadRequest, "GET", "/api/books/events", "", "Last-Event-ID", "2")
+
+	hub.Close()
+	if m := behind.next(); m != "EOF" {
+		t.Fatalf("stream open after hub closed: %s", m)
+	}
+	waitFor(t, func() bool { return hub.Subscribers() == 0 })
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/service"
+)
+
+type AuthorHandler struct {
+	Service service.AuthorService
+}
+
+func NewAuthorHandler(s service.AuthorService) *AuthorHandler {
+	return &AuthorHandler{Service: s}
+}
+
+func (h *AuthorHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	authors, err := h.Service.ListAuthors()
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, authors, "Success", http.StatusOK)
+}
+
+func (h *AuthorHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var author models.Author
+	if err := response.Decode(r, &author); err != nil {
+		response.Error(w, err)
+		return
+	}
+	if err := h.Service.CreateAuthor(r.Context(), &author); err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, author, "Success", http.StatusCreated)
+}
+
+func (h *AuthorHandler) GetById(w http.ResponseWriter, r *http.Request) {
+	author, err := h.Service.GetAuthor(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, author, "Success", http.StatusOK)
+}
+
+func (h *AuthorHandler) PutById(w http.ResponseWriter, r *http.Request) {
+	var author models.Author
+	if err := response.Decode(r, &author); err != nil {
+		response.Error(w, err)
+		return
+	}
+	updated, err := h.Service.UpdateAuthor(r.Context(), r.PathValue("id"), author)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, updated, "Updated success", http.StatusOK)
+}
+
+func (h *AuthorHandler) DeleteById(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.DeleteAuthor(r.Context(), r.PathValue("id")); err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, nil, "data deleted!", http.StatusOK)
+}
+
+// Books serves GET /api/authors/{id}/books, which takes the same filter,
+// sort and pagination parameters as GET /api/books.
+func (h *AuthorHandler) Books(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+
+	page, err := h.Service.AuthorBooks(id, query)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	meta := response.Meta{Total: page.Total, Limit: page.Limit, Offset: page.Offset}
+	response.JSONPage(w, page.Books, meta, "Success", http.StatusOK)
+}
@@ -1,12 +1,15 @@
 package handlers
 
 import (
-	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
-	"strings"
+	"strconv"
 
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/patch"
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/service"
 )
 
@@ -21,88 +24,134 @@ func NewBookHandler(s service.BookService) *BookHandler {
 
 func (h *BookHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var book models.Book
-	if err := json.NewDecoder(r.Body).Decode(&book); err != nil {
-		response.Error(w, err, http.StatusBadRequest)
+	if err := response.Decode(r, &book); err != nil {
+		response.Error(w, err)
 		return
 	}
-	if err := h.Service.CreateBook(&book); err != nil {
-		if err == response.ErrBookAlreadyExist {
-			response.Error(w, err, http.StatusNotFound)
-			return
-		}
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+	if err := h.Service.CreateBook(r.Context(), &book); err != nil {
+		response.Error(w, err)
 		return
 	}
+	w.Header().Set("ETag", etag(book.Version))
 	response.JSON(w, book, "Success", http.StatusCreated)
 }
 
 func (h *BookHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	title := query.Get("title")
-	author := query.Get("author")
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
 
-	if title != "" {
-		book, err := h.Service.SearchBooksByTitle(title)
-		if err != nil {
-			response.Error(w, err, http.StatusNotFound)
-			return
-		}
-		response.JSON(w, book, "Success", http.StatusOK)
+	page, err := h.Service.ListBooks(query)
+	if err != nil {
+		response.Error(w, err)
 		return
 	}
+	meta := response.Meta{Total: page.Total, Limit: page.Limit, Offset: page.Offset}
+	response.JSONPage(w, page.Books, meta, "Success", http.StatusOK)
+}
 
-	if author != "" {
-		book, err := h.Service.SearchBooksByAuthor(author)
-		if err != nil {
-			response.Error(w, err, http.StatusNotFound)
+func (h *BookHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 0
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			response.Error(w, fmt.Errorf("%w: limit must be a non-negative integer", response.ErrInvalidQuery))
 			return
 		}
-		response.JSON(w, book, "Success", http.StatusOK)
-		return
+		limit = n
 	}
 
-	books, err := h.Service.GetAllBooks()
+	books, err := h.Service.SearchBooks(query.Get("q"), limit)
 	if err != nil {
-		if err == response.ErrNoBooks {
-			response.Error(w, err, http.StatusNotFound)
-			return
-		}
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		response.Error(w, err)
+		return
 	}
 	response.JSON(w, books, "Success", http.StatusOK)
 }
 
 func (h *BookHandler) GetById(w http.ResponseWriter, r *http.Request) {
-	id := extractID(r.URL.Path)
+	id := r.PathValue("id")
 	book, err := h.Service.GetBookByID(id)
 	if err != nil {
-		response.Error(w, err, http.StatusNotFound)
+		response.Error(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(book.Version))
+	if noneMatch(r, book.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 	response.JSON(w, book, "Success", http.StatusOK)
 }
 
 func (h *BookHandler) PutById(w http.ResponseWriter, r *http.Request) {
-	id := extractID(r.URL.Path)
+	id := r.PathValue("id")
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
 	var book models.Book
-	if err := json.NewDecoder(r.Body).Decode(&book); err != nil {
-		response.Error(w, err, http.StatusBadRequest)
+	if err := response.Decode(r, &book); err != nil {
+		response.Error(w, err)
 		return
 	}
 
 	book.ID = id
+	book.Version = version
 
-	if err := h.Service.UpdateBook(id, book); err != nil {
-		response.Error(w, err, http.StatusNotFound)
+	updated, err := h.Service.UpdateBook(r.Context(), id, book)
+	if err != nil {
+		response.Error(w, err)
 		return
 	}
+	w.Header().Set("ETag", etag(updated.Version))
+	response.JSON(w, updated, "Updated success", http.StatusOK)
+}
+
+// PatchById applies an RFC 7396 JSON Merge Patch, so clients can send only
+// the fields they want to change.
+func (h *BookHandler) PatchById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != patch.MergePatchContentType && mediaType != "application/json" {
+		w.Header().Set("Accept-Patch", patch.MergePatchContentType)
+		response.Error(w, fmt.Errorf("%w: use %s", response.ErrUnsupportedMedia, patch.MergePatchContentType))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.Error(w, response.BodyError(err))
+		return
+	}
+
+	book, err := h.Service.PatchBook(r.Context(), id, body, version)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(book.Version))
 	response.JSON(w, book, "Updated success", http.StatusOK)
 }
 
 func (h *BookHandler) DeleteById(w http.ResponseWriter, r *http.Request) {
-	id := extractID(r.URL.Path)
-	if err := h.Service.DeleteBook(id); err != nil {
-		response.Error(w, err, http.StatusNotFound)
+	id := r.PathValue("id")
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	if err := h.Service.DeleteBook(r.Context(), id, version); err != nil {
+		response.Error(w, err)
 		return
 	}
 	response.JSON(w, nil, "data deleted!", http.StatusOK)
@@ -112,7 +161,7 @@ func (h *BookHandler) GetByTitle(w http.ResponseWriter, r *http.Request) {
 	title := r.URL.Query().Get("title")
 	book, err := h.Service.SearchBooksByTitle(title)
 	if err != nil {
-		response.Error(w, err, http.StatusNotFound)
+		response.Error(w, err)
 		return
 	}
 	response.JSON(w, book, "Success", http.StatusOK)
@@ -122,13 +171,8 @@ func (h *BookHandler) GetByAuthor(w http.ResponseWriter, r *http.Request) {
 	author := r.URL.Query().Get("author")
 	book, err := h.Service.SearchBooksByAuthor(author)
 	if err != nil {
-		response.Error(w, err, http.StatusNotFound)
+		response.Error(w, err)
 		return
 	}
 	response.JSON(w, book, "Success", http.StatusOK)
 }
-
-func extractID(path string) string {
-	parts := strings.Split(path, "/")
-	return parts[len(parts)-1]
-}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/service"
+)
+
+const (
+	importCreated   = "created"
+	importDuplicate = "duplicate"
+	importInvalid   = "invalid"
+	importFailed    = "failed"
+)
+
+// csvColumns is the column order written by Export. Import matches columns
+// by header name and ignores id and version, which the server assigns.
+// author_ids holds the IDs separated by csvListSeparator.
+var csvColumns = []string{"id", "title", "author", "published_year", "isbn", "description", "author_ids", "version"}
+
+const csvListSeparator = ";"
+
+type importResult struct {
+	Row    int                   `json:"row" xml:"row"`
+	Status string                `json:"status" xml:"status"`
+	ID     string                `json:"id,omitempty" xml:"id,omitempty"`
+	Error  string                `json:"error,omitempty" xml:"error,omitempty"`
+	Errors []response.FieldError `json:"errors,omitempty" xml:"errors,omitempty"`
+}
+
+type importSummary struct {
+	Created   int            `json:"created" xml:"created"`
+	Duplicate int            `json:"duplicate" xml:"duplicate"`
+	Invalid   int            `json:"invalid" xml:"invalid"`
+	Failed    int            `json:"failed" xml:"failed"`
+	Results   []importResult `json:"results" xml:"results"`
+}
+
+// rowReader yields one book per call and io.EOF when the input is exhausted.
+// A non-nil book with a non-nil error marks a row that could not be parsed;
+// a nil book with a non-nil error means the input is unreadable from there on.
+type rowReader func() (*models.Book, error)
+
+var errRowSyntax = errors.New("malformed row")
+
+// Import creates books from a JSON array, NDJSON or CSV body, chosen by
+// Content-Type, and reports the outcome of every row.
+func (h *BookHandler) Import(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var next rowReader
+	var err error
+	switch mediaType {
+	case "application/json":
+		next, err = jsonArrayRows(r.Body)
+	case "application/x-ndjson", "application/ndjson":
+		next = ndjsonRows(r.Body)
+	case "text/csv":
+		next, err = csvRows(r.Body)
+	default:
+		err = fmt.Errorf("%w: use application/json, application/x-ndjson or text/csv", response.ErrUnsupportedMedia)
+	}
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	// A large import can take longer than the server's write timeout
+	// allows, which would lose the summary; writers without deadlines have
+	// no timeout to lift.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	summary := importSummary{Results: []importResult{}}
+	for row := 1; ; row++ {
+		book, err := next()
+		if err == io.EOF {
+			break
+		}
+		if book == nil {
+			// Rows before this one may already be created, so report what
+			// happened instead of failing the whole request.
+			summary.Invalid++
+			summary.Results = append(summary.Results, importResult{
+				Row:    row,
+				Status: importInvalid,
+				Error:  fmt.Sprintf("unreadable input, import stopped: %v", err),
+			})
+			break
+		}
+
+		result := importResult{Row: row}
+		if err == nil {
+			err = h.Service.CreateBook(r.Context(), book)
+		}
+		var verr *response.ValidationError
+		switch {
+		case err == nil:
+			result.Status = importCreated
+			result.ID = book.ID
+			summary.Created++
+		case errors.Is(err, response.ErrBookAlreadyExist):
+			result.Status = importDuplicate
+			result.Error = err.Error()
+			summary.Duplicate++
+		case errors.As(err, &verr):
+			result.Status = importInvalid
+			result.Error = err.Error()
+			result.Errors = verr.Fields
+			summary.Invalid++
+		case errors.Is(err, errRowSyntax):
+			result.Status = importInvalid
+			result.Error = err.Error()
+			summary.Invalid++
+		default:
+			result.Status = importFailed
+			result.Error = "internal server error"
+			summary.Failed++
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	response.JSON(w, summary, "Import finished", http.StatusOK)
+}
+
+func jsonArrayRows(body io.Reader) (rowReader, error) {
+	dec := json.NewDecoder(body)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("%w: expected a JSON array", response.ErrInvalidBody)
+	}
+	return func() (*models.Book, error) {
+		if !dec.More() {
+			return nil, io.EOF
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		return unmarshalRow(raw)
+	}, nil
+}
+
+// unmarshalRow decodes one JSON or NDJSON row as strictly as single-book
+// request bodies: unknown fields make the row invalid.
+func unmarshalRow(data []byte) (*models.Book, error) {
+	var book models.Book
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&book); err != nil {
+		return &book, fmt.Errorf("%w: %v", errRowSyntax, err)
+	}
+	return &book, nil
+}
+
+func ndjsonRows(body io.Reader) rowReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return func() (*models.Book, error) {
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			return unmarshalRow([]byte(line))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+}
+
+func csvRows(body io.Reader) (rowReader, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing CSV header", response.ErrInvalidBody)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	return func() (*models.Book, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		var book models.Book
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return &book, fmt.Errorf("%w: %v", errRowSyntax, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		book.Title = field("title")
+		book.Author = field("author")
+		book.ISBN = field("isbn")
+		book.Description = field("description")
+		if ids := field("author_ids"); ids != "" {
+			for _, id := range strings.Split(ids, csvListSeparator) {
+				book.AuthorIDs = append(book.AuthorIDs, strings.TrimSpace(id))
+			}
+		}
+		if year := field("published_year"); year != "" {
+			book.PublishedYear, err = strconv.Atoi(year)
+			if err != nil {
+				return &book, fmt.Errorf("%w: published_year %q is not a number", errRowSyntax, year)
+			}
+		}
+		return &book, nil
+	}, nil
+}
+
+// Export streams the whole catalogue as CSV or NDJSON (?format=csv|ndjson,
+// NDJSON by default) in ID order, reading it a page at a time.
+func (h *BookHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+
+	var write func(*models.Book) error
+	var flush func() error
+	switch format {
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		write = func(b *models.Book) error { return enc.Encode(b) }
+		flush = func() error { return nil }
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvColumns); err != nil {
+			return
+		}
+		write = func(b *models.Book) error {
+			return cw.Write([]string{
+				b.ID, b.Title, b.Author, strconv.Itoa(b.PublishedYear), b.ISBN, b.Description,
+				strings.Join(b.AuthorIDs, csvListSeparator), strconv.Itoa(b.Version),
+			})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		response.Error(w, fmt.Errorf("%w: format must be csv or ndjson", response.ErrInvalidQuery))
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="books.`+format+`"`)
+
+	rc := http.NewResponseController(w)
+	// A large catalogue can take longer to send than the server's write
+	// timeout allows; writers without deadlines have no timeout to lift.
+	rc.SetWriteDeadline(time.Time{})
+
+	// Headers are already committed once the first page is written, so
+	// later failures can only cut the stream short. Pages are read by ID
+	// rather than offset, so books created or deleted during the export
+	// do not make it skip or repeat others.
+	after := ""
+	for first := true; ; first = false {
+		books, err := h.Service.ExportBooks(after, service.MaxPageSize)
+		if err != nil {
+			if first {
+				w.Header().Del("Content-Disposition")
+				response.Error(w, err)
+			}
+			return
+		}
+		for _, book := range books {
+			if err := write(book); err != nil {
+				return
+			}
+		}
+		// An empty catalogue still gets the CSV header.
+		if err := flush(); err != nil {
+			return
+		}
+		rc.Flush()
+		if len(books) < service.MaxPageSize {
+			break
+		}
+		after = books[len(books)-1].ID
+	}
+}
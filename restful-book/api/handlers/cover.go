@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/service"
+)
+
+// coverField is the multipart form field carrying the image.
+const coverField = "cover"
+
+// MaxCoverRequest is the body limit the router sets on cover uploads, which
+// leaves room for the multipart framing around the image.
+const MaxCoverRequest = service.MaxCoverSize + 64<<10
+
+// PutCover serves PUT /api/books/{id}/cover, a multipart/form-data upload
+// with the image in the "cover" field.
+func (h *BookHandler) PutCover(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	parts, err := r.MultipartReader()
+	if err != nil {
+		response.Error(w, fmt.Errorf("%w: upload the image as multipart/form-data", response.ErrUnsupportedMedia))
+		return
+	}
+
+	var image []byte
+	for image == nil {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			response.Error(w, fmt.Errorf("%w: missing %q field", response.ErrInvalidBody, coverField))
+			return
+		}
+		if err != nil {
+			response.Error(w, uploadError(err))
+			return
+		}
+		if part.FormName() == coverField {
+			image, err = io.ReadAll(io.LimitReader(part, service.MaxCoverSize+1))
+			if err != nil {
+				response.Error(w, uploadError(err))
+				return
+			}
+		}
+		part.Close()
+	}
+
+	book, err := h.Service.SetCover(r.Context(), id, image)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(book.Version))
+	response.JSON(w, book, "Updated success", http.StatusOK)
+}
+
+func uploadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("%w: covers are limited to %d bytes", response.ErrCoverTooLarge, service.MaxCoverSize)
+	}
+	return fmt.Errorf("%w: %v", response.ErrInvalidBody, err)
+}
+
+// GetCover serves GET /api/books/{id}/cover.
+func (h *BookHandler) GetCover(w http.ResponseWriter, r *http.Request) {
+	h.serveCover(w, r, r.PathValue("id"), false)
+}
+
+// GetThumbnail serves GET /api/books/{id}/cover/thumbnail.
+func (h *BookHandler) GetThumbnail(w http.ResponseWriter, r *http.Request) {
+	h.serveCover(w, r, r.PathValue("id"), true)
+}
+
+// serveCover lets caches keep the image but revalidate it on every use: the
+// URL stays the same when the cover changes, and the content-hash ETag makes
+// revalidation a cheap 304. http.ServeContent handles the conditional and
+// range headers.
+func (h *BookHandler) serveCover(w http.ResponseWriter, r *http.Request, id string, thumbnail bool) {
+	file, cover, err := h.Service.Cover(r.Context(), id, thumbnail)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	defer file.Close()
+
+	contentType, tag := cover.ContentType, `"`+cover.SHA256+`"`
+	if thumbnail {
+		contentType, tag = service.ThumbnailContentType, `"`+cover.SHA256+`-thumb"`
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", "public, no-cache")
+	http.ServeContent(w, r, "", cover.UpdatedAt, file)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// etag renders a book version as a strong entity tag.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion returns the version a conditional write expects, or 0 when
+// the request has no If-Match header or uses "*". A tag that is not one of
+// ours can never match, so it yields ErrVersionMismatch.
+func ifMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	// If-Match uses strong comparison, so weak tags never match.
+	tag, ok := strings.CutPrefix(header, `"`)
+	if !ok {
+		return 0, response.ErrVersionMismatch
+	}
+	tag, ok = strings.CutSuffix(tag, `"`)
+	if !ok {
+		return 0, response.ErrVersionMismatch
+	}
+	version, err := strconv.Atoi(tag)
+	if err != nil || version <= 0 {
+		return 0, response.ErrVersionMismatch
+	}
+	return version, nil
+}
+
+// noneMatch reports whether the If-None-Match header lists the current
+// version, using the weak comparison RFC 9110 prescribes for this header.
+func noneMatch(r *http.Request, version int) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/stream"
+)
+
+const (
+	// heartbeatInterval keeps idle streams alive through proxies and
+	// notices clients that went away without closing the connection.
+	heartbeatInterval = 15 * time.Second
+	// reconnectDelay is the retry time, in milliseconds, sent to clients.
+	reconnectDelay = 3000
+)
+
+type EventsHandler struct {
+	Hub *stream.Hub
+}
+
+func NewEventsHandler(hub *stream.Hub) *EventsHandler {
+	return &EventsHandler{Hub: hub}
+}
+
+// Stream serves GET /api/books/events as a Server-Sent Events stream with
+// one message per book event: the id is the stream.Message ID, the event
+// name the event type and the data the JSON models.Event. A client that
+// reconnects with Last-Event-ID first gets the buffered events it missed,
+// preceded by a resync message if some are no longer buffered or the id is
+// from before a restart.
+func (h *EventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	lastID := r.Header.Get("Last-Event-ID")
+	if _, _, ok := stream.ParseID(lastID); lastID != "" && !ok {
+		response.Error(w, response.ErrInvalidEventID)
+		return
+	}
+
+	sub, replay, complete := h.Hub.Subscribe(lastID)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	// The stream is meant to outlive the server's write timeout; writers
+	// without deadlines have no timeout to lift.
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay)
+	if !complete {
+		io.WriteString(w, "event: resync\ndata: some events after Last-Event-ID were missed; reload the catalogue\n\n")
+	}
+	for _, msg := range replay {
+		if err := writeEvent(w, msg); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind, or shutting down: the client
+				// reconnects and resumes from the buffer.
+				return
+			}
+			err = writeEvent(w, msg)
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w io.Writer, msg stream.Message) error {
+	data, err := json.Marshal(msg.Event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", msg.ID, msg.Event.Type, data)
+	return err
+}
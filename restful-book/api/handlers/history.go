@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// History serves GET /api/books/{id}/history.
+func (h *BookHandler) History(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	revisions, err := h.Service.History(r.Context(), id)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, revisions, "Success", http.StatusOK)
+}
+
+// revertRequest is the body of POST /api/books/{id}/revert.
+type revertRequest struct {
+	Revision int `json:"revision" xml:"revision"`
+}
+
+// Revert serves POST /api/books/{id}/revert, which honours If-Match like the
+// other writes.
+func (h *BookHandler) Revert(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	var req revertRequest
+	if err := response.Decode(r, &req); err != nil {
+		response.Error(w, err)
+		return
+	}
+	if req.Revision <= 0 {
+		response.Error(w, fmt.Errorf("%w: revision must be a positive integer", response.ErrInvalidBody))
+		return
+	}
+
+	book, err := h.Service.RevertBook(r.Context(), id, req.Revision, version)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(book.Version))
+	response.JSON(w, book, "Reverted success", http.StatusOK)
+}
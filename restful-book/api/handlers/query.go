@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// parseBookQuery reads the filter, sort and pagination parameters of
+// GET /api/books, e.g. ?author=Jane&year_from=1990&sort=published_year,-title&limit=10&offset=20.
+// author_id filters on AuthorIDs rather than the free-text author.
+func parseBookQuery(values url.Values) (models.BookQuery, error) {
+	q := models.BookQuery{
+		Title:      values.Get("title"),
+		Author:     values.Get("author"),
+		AuthorID:   values.Get("author_id"),
+		ISBNPrefix: values.Get("isbn_prefix"),
+	}
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"year_from", &q.YearFrom},
+		{"year_to", &q.YearTo},
+		{"limit", &q.Limit},
+		{"offset", &q.Offset},
+	}
+	for _, p := range ints {
+		raw := values.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("%w: %s must be a non-negative integer", response.ErrInvalidQuery, p.name)
+		}
+		*p.dst = n
+	}
+	if q.YearFrom != 0 && q.YearTo != 0 && q.YearFrom > q.YearTo {
+		return q, fmt.Errorf("%w: year_from is after year_to", response.ErrInvalidQuery)
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		for _, field := range strings.Split(raw, ",") {
+			desc := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+			if !models.BookSortFields[field] {
+				return q, fmt.Errorf("%w: cannot sort by %q", response.ErrInvalidQuery, field)
+			}
+			q.Sort = append(q.Sort, models.SortField{Field: field, Desc: desc})
+		}
+	}
+	return q, nil
+}
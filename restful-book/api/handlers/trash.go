@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// Trash lists the deleted books the caller can still restore.
+func (h *BookHandler) Trash(w http.ResponseWriter, r *http.Request) {
+	books, err := h.Service.TrashedBooks(r.Context())
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, books, "Success", http.StatusOK)
+}
+
+// Restore serves POST /api/books/{id}/restore.
+func (h *BookHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	book, err := h.Service.RestoreBook(r.Context(), id)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(book.Version))
+	response.JSON(w, book, "Restored success", http.StatusOK)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/service"
+)
+
+type WebhookHandler struct {
+	Service service.WebhookService
+}
+
+func NewWebhookHandler(s service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{Service: s}
+}
+
+func (h *WebhookHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.Service.ListWebhooks(r.Context())
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, webhooks, "Success", http.StatusOK)
+}
+
+// Create serves POST /api/webhooks. The response is the only one that
+// carries the webhook's signing secret.
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var webhook models.Webhook
+	if err := response.Decode(r, &webhook); err != nil {
+		response.Error(w, err)
+		return
+	}
+	if err := h.Service.CreateWebhook(r.Context(), &webhook); err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, webhook, "Success", http.StatusCreated)
+}
+
+func (h *WebhookHandler) GetById(w http.ResponseWriter, r *http.Request) {
+	webhook, err := h.Service.GetWebhook(r.Context(), r.PathValue("id"))
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, webhook, "Success", http.StatusOK)
+}
+
+func (h *WebhookHandler) DeleteById(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.DeleteWebhook(r.Context(), r.PathValue("id")); err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, nil, "data deleted!", http.StatusOK)
+}
+
+// DeadLetters serves GET /api/webhooks/{id}/dead-letters.
+func (h *WebhookHandler) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	letters, err := h.Service.DeadLetters(r.Context(), id)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSON(w, letters, "Success", http.StatusOK)
+}
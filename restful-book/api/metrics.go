@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"math"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/metrics"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/service"
+)
+
+// MetricsPath is where the Prometheus metrics are served.
+const MetricsPath = "/metrics"
+
+// registerStorageMetrics exposes the repository size and, when the read
+// cache is enabled, its hit and miss counts. A failed read reports NaN.
+func registerStorageMetrics(reg *metrics.Registry, books repository.BookRepository, cache *service.CachingBookService) {
+	reg.NewGaugeFunc("books", "Books in the catalogue, not counting the trash.", func() float64 {
+		_, total, err := books.Query(models.BookQuery{Limit: 1})
+		if errors.Is(err, response.ErrNoBooks) {
+			return 0
+		}
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(total)
+	})
+	reg.NewGaugeFunc("books_trashed", "Deleted books waiting to be purged.", func() float64 {
+		trash, err := books.Trash()
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(len(trash))
+	})
+
+	if cache == nil {
+		return
+	}
+	reg.NewCounterFunc("book_cache_hits_total", "Book reads served from the cache.", func() float64 {
+		return float64(cache.Stats().Hits)
+	})
+	reg.NewCounterFunc("book_cache_misses_total", "Book reads that missed the cache.", func() float64 {
+		return float64(cache.Stats().Misses)
+	})
+	reg.NewCounterFunc("book_cache_evictions_total", "Cached book reads evicted to stay within the size limit.", func() float64 {
+		return float64(cache.Stats().Evictions)
+	})
+	reg.NewGaugeFunc("book_cache_entries", "Book reads currently cached.", func() float64 {
+		return float64(cache.Stats().Entries)
+	})
+}
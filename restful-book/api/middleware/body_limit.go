@@ -0,0 +1,14 @@
+package middleware
+
+import "net/http"
+
+// MaxBodyBytes caps request bodies at n bytes. Reading past the cap fails
+// with *http.MaxBytesError, which response.BodyError turns into a 413.
+func MaxBodyBytes(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/metrics"
+)
+
+// UnmatchedRoute labels requests no route claimed with SetRoute.
+const UnmatchedRoute = "unmatched"
+
+type routeKey struct{}
+
+// Metrics counts requests by route, method and status, and records their
+// latency and how many are in flight. Routes are labelled with the template
+// the router passes to SetRoute, never the raw path, so label values stay
+// bounded.
+func Metrics(reg *metrics.Registry) Middleware {
+	requests := reg.NewCounterVec("http_requests_total",
+		"HTTP requests served, by route, method and status code.", "route", "method", "status")
+	latency := reg.NewHistogramVec("http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by route and method.", metrics.DefaultBuckets, "route", "method")
+	inFlight := reg.NewGauge("http_requests_in_flight", "HTTP requests currently being served.")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inFlight.Add(1)
+			defer inFlight.Add(-1)
+
+			route := UnmatchedRoute
+			ctx := context.WithValue(r.Context(), routeKey{}, &route)
+			rec := newStatusRecorder(w)
+			start := time.Now()
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			status := rec.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			method := metricMethod(r.Method)
+			requests.Inc(route, method, strconv.Itoa(status))
+			latency.Observe(time.Since(start).Seconds(), route, method)
+		})
+	}
+}
+
+// SetRoute names the route template serving the request, e.g.
+// "/api/books/{id}", for the Metrics middleware.
+func SetRoute(ctx context.Context, route string) {
+	if p, ok := ctx.Value(routeKey{}).(*string); ok {
+		*p = route
+	}
+}
+
+// metricMethod folds methods the API does not use into one label value.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/requestid"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestid.From(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := serve(h, req)
+	if seen != "abc-123" || rec.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Fatalf("client ID not propagated: context %q, header %q", seen, rec.Header().Get(RequestIDHeader))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id")
+	rec = serve(h, req)
+	if seen == "bad id" || seen == "" || rec.Header().Get(RequestIDHeader) != seen {
+		t.Fatalf("invalid ID not replaced: context %q, header %q", seen, rec.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), RequestID, Logger(discardLogger), Recover(discardLogger))
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"code":"internal"`) || strings.Contains(body, "boom") {
+		t.Fatalf("body = %s, want a generic internal error", body)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	h := MaxBodyBytes(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			response.Error(w, response.BodyError(err))
+		}
+	}))
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("1234"))); rec.Code != http.StatusOK {
+		t.Fatalf("body at the limit: status %d", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345"))); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("body over the limit: status %d, want 413", rec.Code)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := RateLimit(RateLimitConfig{PerSecond: 1, Burst: 2})(ok)
+	request := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		return serve(h, req)
+	}
+
+	for i := range 2 {
+		if rec := request("192.0.2.1:1000"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within burst: status %d", i, rec.Code)
+		}
+	}
+	rec := request("192.0.2.1:2000")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("request over burst: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := request("192.0.2.2:1000"); rec.Code != http.StatusOK {
+		t.Fatalf("other client: status %d", rec.Code)
+	}
+
+	unlimited := RateLimit(RateLimitConfig{})(ok)
+	for i := range 10 {
+		if rec := serve(unlimited, httptest.NewRequest(http.MethodGet, "/", nil)); rec.Code != http.StatusOK {
+			t.Fatalf("disabled limiter, request %d: status %d", i, rec.Code)
+		}
+	}
+}
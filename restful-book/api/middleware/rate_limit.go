@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// RateLimitConfig sets the token bucket each client gets: PerSecond
+// requests are refilled every second up to Burst. A zero PerSecond
+// disables rate limiting.
+type RateLimitConfig struct {
+	PerSecond float64
+	Burst     int
+}
+
+// clientIdleTimeout is how long a client's bucket is kept after its last
+// request. A full bucket carries no state worth keeping, so this only
+// needs to outlast the refill time.
+const clientIdleTimeout = 10 * time.Minute
+
+type rateClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimit gives every client, identified by its remote IP address, its
+// own token bucket. Requests that find the bucket empty get 429 with a
+// Retry-After header saying when a token will be available.
+func RateLimit(cfg RateLimitConfig) Middleware {
+	if cfg.PerSecond <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	burst := max(cfg.Burst, 1)
+
+	var mu sync.Mutex
+	clients := map[string]*rateClient{}
+	lastSweep := time.Now()
+
+	reserve := func(key string, now time.Time) *rate.Reservation {
+		mu.Lock()
+		defer mu.Unlock()
+		if now.Sub(lastSweep) > clientIdleTimeout {
+			for k, c := range clients {
+				if now.Sub(c.lastSeen) > clientIdleTimeout {
+					delete(clients, k)
+				}
+			}
+			lastSweep = now
+		}
+		c, ok := clients[key]
+		if !ok {
+			c = &rateClient{limiter: rate.NewLimiter(rate.Limit(cfg.PerSecond), burst)}
+			clients[key] = c
+		}
+		c.lastSeen = now
+		return c.limiter.ReserveN(now, 1)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			now := time.Now()
+			res := reserve(clientKey(r), now)
+			if delay := res.DelayFrom(now); delay > 0 {
+				// Give the token back: a rejected request should not push
+				// the client's next allowed request further out.
+				res.CancelAt(now)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				response.Error(w, response.ErrRateLimited)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the client by IP address. Forwarding headers are
+// ignored because any client can set them.
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/requestid"
+)
+
+// Recover turns a panicking handler into a logged 500 with the standard JSON
+// error envelope instead of a dropped connection.
+func Recover(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newStatusRecorder(w)
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					// Deliberate abort; let net/http handle it quietly.
+					panic(v)
+				}
+				logger.ErrorContext(r.Context(), "panic serving request",
+					slog.String("request_id", requestid.From(r.Context())),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Any("panic", v),
+					slog.String("stack", string(debug.Stack())),
+				)
+				// Once the handler has started the response we can only
+				// cut it short.
+				if rec.Status() != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				response.Error(rec, fmt.Errorf("panic: %v", v))
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
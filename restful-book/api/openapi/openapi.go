@@ -0,0 +1,136 @@
+// Package openapi serves the OpenAPI 3 description of the books API and
+// checks it against what the server actually exposes.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+const Path = "/api/openapi.json"
+
+// Route is one method and OpenAPI path template, e.g. GET /api/books/{id}.
+type Route struct {
+	Method string
+	Path   string
+}
+
+func (r Route) String() string {
+	return r.Method + " " + r.Path
+}
+
+// Document returns a copy of the embedded OpenAPI document.
+func Document() []byte {
+	return slices.Clone(document)
+}
+
+// Handler serves the document as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	})
+}
+
+type spec struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+var operationKeys = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Verify reports every difference between the document and the server: routes
+// that are served but undocumented or documented but not served, and JSON
+// fields of the given types (keyed by schema name) missing from or extra in
+// the matching component schema. The api tests call it with every route the
+// router serves, so a route or field cannot ship without its documentation.
+func Verify(routes []Route, schemas map[string]any) error {
+	var s spec
+	if err := json.Unmarshal(document, &s); err != nil {
+		return fmt.Errorf("openapi: parse document: %w", err)
+	}
+
+	var problems []string
+
+	documented := map[Route]bool{}
+	for path, item := range s.Paths {
+		for key := range item {
+			if slices.Contains(operationKeys, key) {
+				documented[Route{Method: strings.ToUpper(key), Path: path}] = true
+			}
+		}
+	}
+	served := map[Route]bool{}
+	for _, r := range routes {
+		served[r] = true
+		if !documented[r] {
+			problems = append(problems, "route "+r.String()+" is not documented")
+		}
+	}
+	for r := range documented {
+		if !served[r] {
+			problems = append(problems, "route "+r.String()+" is documented but not served")
+		}
+	}
+
+	for name, v := range schemas {
+		schema, ok := s.Components.Schemas[name]
+		if !ok {
+			problems = append(problems, "schema "+name+" is not documented")
+			continue
+		}
+		fields := jsonFields(reflect.TypeOf(v))
+		for _, f := range fields {
+			if _, ok := schema.Properties[f]; !ok {
+				problems = append(problems, fmt.Sprintf("schema %s is missing field %q", name, f))
+			}
+		}
+		for f := range schema.Properties {
+			if !slices.Contains(fields, f) {
+				problems = append(problems, fmt.Sprintf("schema %s documents unknown field %q", name, f))
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	slices.Sort(problems)
+	return errors.New("openapi: document out of date:\n\t" + strings.Join(problems, "\n\t"))
+}
+
+// jsonFields lists the names encoding/json uses for the exported fields of t.
+func jsonFields(t reflect.Type) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,43 @@
+package openapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDocumentIsValidJSON(t *testing.T) {
+	var v map[string]any
+	if err := json.Unmarshal(Document(), &v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyReportsDrift(t *testing.T) {
+	type book struct {
+		ID    string `json:"id"`
+		Extra int    `json:"extra,omitempty"`
+		Skip  string `json:"-"`
+	}
+	err := Verify(
+		[]Route{{Method: "GET", Path: "/api/undocumented"}},
+		map[string]any{"Book": book{}, "Undocumented": book{}},
+	)
+	if err == nil {
+		t.Fatal("Verify accepted a drifted document")
+	}
+	for _, want := range []string{
+		"route GET /api/undocumented is not documented",
+		"route GET /api/books is documented but not served",
+		`schema Book is missing field "extra"`,
+		`schema Book documents unknown field "title"`,
+		"schema Undocumented is not documented",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Verify error lacks %q", want)
+		}
+	}
+	if strings.Contains(err.Error(), `"Skip"`) || strings.Contains(err.Error(), `"-"`) {
+		t.Errorf("Verify reported a json:\"-\" field:\n%v", err)
+	}
+}
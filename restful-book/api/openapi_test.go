@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/api/openapi"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+)
+
+// TestOpenAPIDocument fails when a route or a JSON field ships without its
+// documentation in openapi.json, or the document describes something the
+// server no longer has.
+func TestOpenAPIDocument(t *testing.T) {
+	_, served, err := newRouter(repository.NewBookRepository(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = openapi.Verify(served, map[string]any{
+		"Book":             models.Book{},
+		"Cover":            models.Cover{},
+		"Author":           models.Author{},
+		"StandardResponse": response.StandardResponse{},
+		"Meta":             response.Meta{},
+		"FieldError":       response.FieldError{},
+		"Revision":         models.Revision{},
+		"FieldChange":      models.FieldChange{},
+		"Webhook":          models.Webhook{},
+		"Event":            models.Event{},
+		"DeadLetter":       models.DeadLetter{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,42 +1,334 @@
 package api
 
 import (
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/api/handlers"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/api/middleware"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/api/openapi"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/blob"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/metrics"
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/service"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/stream"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/webhook"
 )
 
-func NewRouter() http.Handler {
+// route serves path, an OpenAPI path template such as "/api/books/{id}"
+// that doubles as the ServeMux pattern, with one handler per HTTP method.
+// The paths of sub are relative to path, so sub-resources are declared
+// inside the resource they belong to.
+type route struct {
+	path    string
+	methods map[string]http.HandlerFunc
+	sub     []route
+}
+
+// Options configures NewRouter.
+type Options struct {
+	// APIKeys authenticates callers of mutating routes. With no keys every
+	// write is rejected with 401.
+	APIKeys *auth.APIKeys
+	// Authors stores the authors books can credit. It defaults to an
+	// in-memory repository.
+	Authors repository.AuthorRepository
+	// Cache sizes the book read cache; the zero value disables it.
+	Cache service.CacheConfig
+	// Covers stores cover images. It defaults to an in-memory store.
+	Covers blob.Store
+	// Webhooks stores webhook subscriptions and their dead letters. It
+	// defaults to an in-memory repository.
+	Webhooks repository.WebhookRepository
+	// Dispatcher delivers book events to Webhooks. It defaults to one with
+	// webhook.DefaultConfig; pass one to be able to Close it on shutdown.
+	Dispatcher *webhook.Dispatcher
+	// Stream feeds GET /api/books/events. It defaults to a Hub with
+	// stream.DefaultBufferSize; pass one to be able to Close it on shutdown.
+	Stream *stream.Hub
+	// MaxBodyBytes caps request bodies on routes that do not set their own
+	// limit; zero means DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+	// RateLimit sets each client's request budget; the zero value disables
+	// rate limiting.
+	RateLimit middleware.RateLimitConfig
+}
+
+// DefaultMaxBodyBytes is the body limit when Options.MaxBodyBytes is zero.
+const DefaultMaxBodyBytes = 1 << 20
+
+// bodyLimits overrides the body limit of the routes that take uploads.
+var bodyLimits = map[openapi.Route]int64{
+	{Method: http.MethodPost, Path: "/api/books/bulk"}:      32 << 20,
+	{Method: http.MethodPut, Path: "/api/books/{id}/cover"}: handlers.MaxCoverRequest,
+}
+
+func NewRouter(bookRepo repository.BookRepository, opts Options) (http.Handler, error) {
+	h, _, err := newRouter(bookRepo, opts)
+	return h, err
+}
 
-	bookRepo := repository.NewBookRepository()
-	bookService := service.NewBookService(bookRepo)
+// newRouter builds the router and also returns every route it serves, which
+// the tests check against the OpenAPI document.
+func newRouter(bookRepo repository.BookRepository, opts Options) (http.Handler, []openapi.Route, error) {
+	authorRepo := opts.Authors
+	if authorRepo == nil {
+		authorRepo = repository.NewAuthorRepository()
+	}
+	covers := opts.Covers
+	if covers == nil {
+		covers = blob.NewMemoryStore()
+	}
+	webhookRepo := opts.Webhooks
+	if webhookRepo == nil {
+		webhookRepo = repository.NewWebhookRepository()
+	}
+	dispatcher := opts.Dispatcher
+	if dispatcher == nil {
+		dispatcher = webhook.NewDispatcher(webhookRepo, webhook.DefaultConfig)
+	}
+	hub := opts.Stream
+	if hub == nil {
+		hub = stream.NewHub(stream.DefaultBufferSize)
+	}
+	events := service.Publishers{dispatcher, hub}
+	bookService, err := service.NewBookService(bookRepo, authorRepo, covers, events)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cache *service.CachingBookService
+	if opts.Cache.TTL > 0 && opts.Cache.Size > 0 {
+		cache = service.NewCachingBookService(bookService, opts.Cache)
+		bookService = cache
+	}
 	bookHandler := handlers.NewBookHandler(bookService)
+	authorHandler := handlers.NewAuthorHandler(service.NewAuthorService(authorRepo, bookRepo))
+	webhookHandler := handlers.NewWebhookHandler(service.NewWebhookService(webhookRepo))
+	eventsHandler := handlers.NewEventsHandler(hub)
+
+	reg := metrics.NewRegistry()
+	instrument := middleware.Metrics(reg)
+	registerStorageMetrics(reg, bookRepo, cache)
+	reg.NewGaugeFunc("book_event_subscribers", "Clients connected to the book event stream.", func() float64 {
+		return float64(hub.Subscribers())
+	})
+
+	routes := []route{
+		{path: "/api/books", methods: map[string]http.HandlerFunc{
+			http.MethodGet:  bookHandler.GetAll,
+			http.MethodPost: bookHandler.Create,
+		}, sub: []route{
+			{path: "/search", methods: map[string]http.HandlerFunc{
+				http.MethodGet: bookHandler.Search,
+			}},
+			{path: "/bulk", methods: map[string]http.HandlerFunc{
+				http.MethodPost: bookHandler.Import,
+			}},
+			{path: "/export", methods: map[string]http.HandlerFunc{
+				http.MethodGet: bookHandler.Export,
+			}},
+			{path: "/events", methods: map[string]http.HandlerFunc{
+				http.MethodGet: eventsHandler.Stream,
+			}},
+			{path: "/trash", methods: map[string]http.HandlerFunc{
+				http.MethodGet: bookHandler.Trash,
+			}},
+			{path: "/{id}", methods: map[string]http.HandlerFunc{
+				http.MethodGet:    bookHandler.GetById,
+				http.MethodPut:    bookHandler.PutById,
+				http.MethodPatch:  bookHandler.PatchById,
+				http.MethodDelete: bookHandler.DeleteById,
+			}, sub: []route{
+				{path: "/restore", methods: map[string]http.HandlerFunc{
+					http.MethodPost: bookHandler.Restore,
+				}},
+				{path: "/history", methods: map[string]http.HandlerFunc{
+					http.MethodGet: bookHandler.History,
+				}},
+				{path: "/revert", methods: map[string]http.HandlerFunc{
+					http.MethodPost: bookHandler.Revert,
+				}},
+				{path: "/cover", methods: map[string]http.HandlerFunc{
+					http.MethodGet: bookHandler.GetCover,
+					http.MethodPut: bookHandler.PutCover,
+				}, sub: []route{
+					{path: "/thumbnail", methods: map[string]http.HandlerFunc{
+						http.MethodGet: bookHandler.GetThumbnail,
+					}},
+				}},
+			}},
+		}},
+		{path: "/api/authors", methods: map[string]http.HandlerFunc{
+			http.MethodGet:  authorHandler.GetAll,
+			http.MethodPost: authorHandler.Create,
+		}, sub: []route{
+			{path: "/{id}", methods: map[string]http.HandlerFunc{
+				http.MethodGet:    authorHandler.GetById,
+				http.MethodPut:    authorHandler.PutById,
+				http.MethodDelete: authorHandler.DeleteById,
+			}, sub: []route{
+				{path: "/books", methods: map[string]http.HandlerFunc{
+					http.MethodGet: authorHandler.Books,
+				}},
+			}},
+		}},
+		{path: "/api/webhooks", methods: map[string]http.HandlerFunc{
+			http.MethodGet:  webhookHandler.GetAll,
+			http.MethodPost: webhookHandler.Create,
+		}, sub: []route{
+			{path: "/{id}", methods: map[string]http.HandlerFunc{
+				http.MethodGet:    webhookHandler.GetById,
+				http.MethodDelete: webhookHandler.DeleteById,
+			}, sub: []route{
+				{path: "/dead-letters", methods: map[string]http.HandlerFunc{
+					http.MethodGet: webhookHandler.DeadLetters,
+				}},
+			}},
+		}},
+		{path: openapi.Path, methods: map[string]http.HandlerFunc{
+			http.MethodGet: openapi.Handler().ServeHTTP,
+		}},
+		{path: MetricsPath, methods: map[string]http.HandlerFunc{
+			http.MethodGet: reg.Handler().ServeHTTP,
+		}},
+	}
+
+	maxBody := opts.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBodyBytes
+	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/books", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			bookHandler.GetAll(w, r)
-		case http.MethodPost:
-			bookHandler.Create(w, r)
-		default:
-			http.NotFound(w, r)
+	var served []openapi.Route
+	for _, rt := range flatten("", routes) {
+		for method, h := range rt.methods {
+			route := openapi.Route{Method: method, Path: rt.path}
+			limit, ok := bodyLimits[route]
+			if !ok {
+				limit = maxBody
+			}
+			handler := middleware.MaxBodyBytes(limit)(h)
+			if mutating(method) || private[route] {
+				handler = auth.Require(opts.APIKeys, handler)
+			}
+			if !ownFormat[route] {
+				handler = response.Negotiate(handler)
+			}
+			mux.Handle(method+" "+rt.path, named(rt.path, handler))
+			served = append(served, route)
+		}
+	}
+
+	logger := slog.Default()
+	return middleware.Chain(fallback(mux),
+		middleware.RequestID,
+		instrument,
+		middleware.Logger(logger),
+		middleware.Recover(logger),
+		middleware.RateLimit(opts.RateLimit),
+	), served, nil
+}
+
+// private lists the read routes that, like every write, need an API key.
+var private = map[openapi.Route]bool{
+	{Method: http.MethodGet, Path: "/api/books/trash"}:                true,
+	{Method: http.MethodGet, Path: "/api/books/{id}/history"}:         true,
+	{Method: http.MethodGet, Path: "/api/webhooks"}:                   true,
+	{Method: http.MethodGet, Path: "/api/webhooks/{id}"}:              true,
+	{Method: http.MethodGet, Path: "/api/webhooks/{id}/dead-letters"}: true,
+}
+
+// ownFormat lists the routes that choose their own representation instead
+// of negotiating one of the StandardResponse encodings.
+var ownFormat = map[openapi.Route]bool{
+	{Method: http.MethodGet, Path: "/api/books/export"}:               true,
+	{Method: http.MethodGet, Path: "/api/books/events"}:               true,
+	{Method: http.MethodGet, Path: "/api/books/{id}/cover"}:           true,
+	{Method: http.MethodGet, Path: "/api/books/{id}/cover/thumbnail"}: true,
+	{Method: http.MethodGet, Path: openapi.Path}:                      true,
+	{Method: http.MethodGet, Path: MetricsPath}:                       true,
+}
+
+// mutating reports whether requests with method change state and therefore
+// need an authenticated caller.
+func mutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// flatten lists routes and their sub-routes, depth first, with each path
+// joined onto the paths of its parents and prefix.
+func flatten(prefix string, routes []route) []route {
+	var flat []route
+	for _, rt := range routes {
+		path := prefix + rt.path
+		if len(rt.methods) > 0 {
+			flat = append(flat, route{path: path, methods: rt.methods})
 		}
+		flat = append(flat, flatten(path, rt.sub)...)
+	}
+	return flat
+}
+
+// named labels the requests h serves with the route path for the Metrics
+// middleware.
+func named(path string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		middleware.SetRoute(r.Context(), path)
+		h.ServeHTTP(w, r)
 	})
+}
+
+// fallback serves the requests mux has no route for. A path with a trailing
+// slash is redirected with 308 Permanent Redirect to the route without it;
+// anything else gets ErrNotFound, or ErrMethodNotAllowed with the Allow
+// header when the path is served for other methods, in place of the plain
+// text errors ServeMux writes.
+func fallback(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		if path := r.URL.Path; len(path) > 1 && strings.HasSuffix(path, "/") {
+			u := *r.URL
+			u.Path = strings.TrimSuffix(path, "/")
+			u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+			trimmed := r.Clone(r.Context())
+			trimmed.URL = &u
+			if _, pattern := mux.Handler(trimmed); pattern != "" {
+				http.Redirect(w, r, u.RequestURI(), http.StatusPermanentRedirect)
+				return
+			}
+		}
 
-	mux.HandleFunc("/api/books/", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			bookHandler.GetById(w, r)
-		case http.MethodPut:
-			bookHandler.PutById(w, r)
-		case http.MethodDelete:
-			bookHandler.DeleteById(w, r)
-		default:
-			http.NotFound(w, r)
+		// Let ServeMux decide between 404 and 405, then write our own error.
+		rec := &headerRecorder{header: http.Header{}}
+		h.ServeHTTP(rec, r)
+		if rec.status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", rec.header.Get("Allow"))
+			response.Error(w, response.ErrMethodNotAllowed)
+			return
 		}
+		response.Error(w, response.ErrNotFound)
 	})
-	return mux
 }
+
+// headerRecorder keeps the header and status a handler writes and discards
+// the body.
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (r *headerRecorder) Header() http.Header         { return r.header }
+func (r *headerRecorder) Write(p []byte) (int, error) { return len(p), nil }
+func (r *headerRecorder) WriteHeader(status int)      { r.status = status }
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+)
+
+// testKeys authenticates alice (k1, the default caller of do), bob (k2) and
+// the admin root (k3).
+const testKeys = "k1:alice,k2:bob,k3:root:admin"
+
+func newTestRouter(t *testing.T, opts Options) http.Handler {
+	t.Helper()
+	if opts.APIKeys == nil {
+		keys, err := auth.ParseAPIKeys(testKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.APIKeys = keys
+	}
+	h, err := NewRouter(repository.NewBookRepository(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// do sends a request as alice; header holds name, value pairs that are set
+// afterwards, so "X-API-Key", "" makes an anonymous request.
+func do(t *testing.T, h http.Handler, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-API-Key", "k1")
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect is do that fails the test unless the response has status code.
+func expect(t *testing.T, h http.Handler, code int, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := do(t, h, method, path, body, header...)
+	if rec.Code != code {
+		t.Fatalf("%s %s: status %d, want %d\n%s", method, path, rec.Code, code, rec.Body)
+	}
+	return rec
+}
+
+// data decodes the data of a JSON StandardResponse into v.
+func data(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	envelope := struct{ Data any }{Data: v}
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+}
+
+// create adds a book as alice and returns its ID.
+func create(t *testing.T, h http.Handler, body string) string {
+	t.Helper()
+	var book struct{ ID string }
+	data(t, expect(t, h, http.StatusCreated, "POST", "/api/books", body), &book)
+	return book.ID
+}
+
+func TestAuth(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	id := create(t, h, `{"title":"T","author":"A"}`)
+	put := `{"title":"T","author":"B"}`
+
+	expect(t, h, http.StatusUnauthorized, "PUT", "/api/books/"+id, put, "X-API-Key", "")
+	expect(t, h, http.StatusUnauthorized, "PUT", "/api/books/"+id, put, "X-API-Key", "bad")
+	expect(t, h, http.StatusForbidden, "PUT", "/api/books/"+id, put, "X-API-Key", "k2")
+	expect(t, h, http.StatusForbidden, "PATCH", "/api/books/"+id, `{"title":"X"}`,
+		"X-API-Key", "", "Authorization", "Bearer k2", "Content-Type", "application/merge-patch+json")
+	expect(t, h, http.StatusForbidden, "DELETE", "/api/books/"+id, "", "X-API-Key", "k2")
+
+	rec := expect(t, h, http.StatusOK, "PUT", "/api/books/"+id, `{"title":"T","author":"B","owner_id":"bob"}`)
+	if !strings.Contains(rec.Body.String(), `"owner_id":"alice"`) {
+		t.Fatalf("owner changed through the request body: %s", rec.Body)
+	}
+	expect(t, h, http.StatusOK, "DELETE", "/api/books/"+id, "", "X-API-Key", "k3")
+	expect(t, h, http.StatusOK, "GET", "/api/openapi.json", "", "X-API-Key", "")
+}
+
+func TestRouting(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	id := create(t, h, `{"title":"T","author":"A"}`)
+
+	rec := expect(t, h, http.StatusNotFound, "GET", "/api/books/"+id+"/extra", "")
+	if !strings.Contains(rec.Body.String(), `"code":"not_found"`) {
+		t.Fatalf("unrouted path: %s", rec.Body)
+	}
+	rec = expect(t, h, http.StatusMethodNotAllowed, "POST", "/api/books/"+id, "{}")
+	if !strings.Contains(rec.Body.String(), `"code":"method_not_allowed"`) {
+		t.Fatalf("wrong method: %s", rec.Body)
+	}
+	if allow := rec.Header().Get("Allow"); !strings.Contains(allow, "PATCH") || !strings.Contains(allow, "HEAD") {
+		t.Fatalf("Allow = %q", allow)
+	}
+	rec = expect(t, h, http.StatusMethodNotAllowed, "GET", "/api/books/"+id+"/restore", "")
+	if allow := rec.Header().Get("Allow"); allow != "POST" {
+		t.Fatalf("Allow = %q, want POST", allow)
+	}
+
+	rec = expect(t, h, http.StatusPermanentRedirect, "GET", "/api/books/?limit=1", "")
+	if loc := rec.Header().Get("Location"); loc != "/api/books?limit=1" {
+		t.Fatalf("Location = %q", loc)
+	}
+	expect(t, h, http.StatusPermanentRedirect, "GET", "/api/books/"+id+"/", "")
+	expect(t, h, http.StatusNotFound, "GET", "/api/nope/", "")
+
+	expect(t, h, http.StatusOK, "HEAD", "/api/books/"+id, "")
+	rec = expect(t, h, http.StatusNotFound, "GET", "/api/books/"+id+"/cover/thumbnail", "")
+	if !strings.Contains(rec.Body.String(), `"code":"cover_not_found"`) {
+		t.Fatalf("nested route not reached: %s", rec.Body)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	h := newTestRouter(t, Options{})
+	id := create(t, h, `{"title":"T","author":"A"}`)
+	expect(t, h, http.StatusOK, "GET", "/api/books/"+id, "")
+	expect(t, h, http.StatusOK, "GET", "/api/books/"+id, "")
+	expect(t, h, http.StatusNotFound, "GET", "/api/nope", "")
+	expect(t, h, http.StatusOK, "DELETE", "/api/books/"+id, "")
+
+	rec := expect(t, h, http.StatusOK, "GET", MetricsPath, "")
+	for _, want := range []string{
+		`http_requests_total{route="/api/books/{id}",method="GET",status="200"} 2`,
+		`http_requests_total{route="unmatched",method="GET",status="404"} 1`,
+		`http_request_duration_seconds_bucket{route="/api/books",method="POST",le="+Inf"} 1`,
+		"http_requests_in_flight 1",
+		"books 0",
+		"books_trashed 1",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+}
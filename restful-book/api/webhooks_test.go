@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/webhook"
+)
+
+func TestWebhooks(t *testing.T) {
+	repo := repository.NewWebhookRepository()
+	dispatcher := webhook.NewDispatcher(repo, webhook.Config{Attempts: 3, Backoff: 10 * time.Millisecond, AllowPrivateNetworks: true})
+	h := newTestRouter(t, Options{Webhooks: repo, Dispatcher: dispatcher})
+
+	secrets := make(chan string, 1)
+	deliveries := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := <-secrets
+		secrets <- secret
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify(secret, r.Header.Get(webhook.SignatureHeader), body, time.Minute, time.Now()); err != nil {
+			t.Error(err)
+		}
+		deliveries <- r.Header.Get(webhook.EventHeader)
+	}))
+	defer receiver.Close()
+	var failures atomic.Int32
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failures.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	expect(t, h, http.StatusUnprocessableEntity, "POST", "/api/webhooks", `{"url":"ftp://x","events":["book.nope"]}`)
+	var created models.Webhook
+	data(t, expect(t, h, http.StatusCreated, "POST", "/api/webhooks",
+		`{"url":"`+receiver.URL+`","events":["book.created","book.deleted"]}`), &created)
+	if created.Secret == "" {
+		t.Fatal("created webhook has no secret")
+	}
+	secrets <- created.Secret
+	var dead models.Webhook
+	data(t, expect(t, h, http.StatusCreated, "POST", "/api/webhooks", `{"url":"`+broken.URL+`"}`), &dead)
+
+	rec := expect(t, h, http.StatusOK, "GET", "/api/webhooks", "")
+	if strings.Contains(rec.Body.String(), created.Secret) || !strings.Contains(rec.Body.String(), dead.ID) {
+		t.Fatalf("webhook list: %s", rec.Body)
+	}
+	expect(t, h, http.StatusUnauthorized, "GET", "/api/webhooks", "", "X-API-Key", "")
+	expect(t, h, http.StatusForbidden, "GET", "/api/webhooks/"+dead.ID+"/dead-letters", "", "X-API-Key", "k2")
+
+	id := create(t, h, `{"title":"T","author":"A"}`)
+	expect(t, h, http.StatusOK, "PUT", "/api/books/"+id, `{"title":"T2","author":"A"}`)
+	expect(t, h, http.StatusOK, "DELETE", "/api/books/"+id, "")
+	seen := map[string]bool{}
+	for range 2 {
+		select {
+		case event := <-deliveries:
+			seen[event] = true
+		case <-time.After(2 * time.Second):
+			t.Fatal("webhook not delivered")
+		}
+	}
+	if !seen[models.EventBookCreated] || !seen[models.EventBookDeleted] {
+		t.Fatalf("delivered %v, want the subscribed events", seen)
+	}
+
+	if err := dispatcher.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := failures.Load(); got != 9 {
+		t.Fatalf("broken endpoint got %d attempts, want 3 events x 3", got)
+	}
+	var letters []models.DeadLetter
+	data(t, expect(t, h, http.StatusOK, "GET", "/api/webhooks/"+dead.ID+"/dead-letters", ""), &letters)
+	if len(letters) != 3 || letters[0].Attempts != 3 || !strings.Contains(letters[0].LastError, "503") {
+		t.Fatalf("dead letters: %+v", letters)
+	}
+	expect(t, h, http.StatusOK, "DELETE", "/api/webhooks/"+dead.ID, "")
+	expect(t, h, http.StatusNotFound, "GET", "/api/webhooks/"+dead.ID+"/dead-letters", "")
+}
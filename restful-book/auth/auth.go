@@ -0,0 +1,124 @@
+// Package auth authenticates API callers and carries the resulting
+// Principal through the request context.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// Principal is the authenticated caller.
+type Principal struct {
+	ID   string
+	Role Role
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// CanModify reports whether p may change a record owned by ownerID. Records
+// without an owner predate authentication and are admin-only.
+func (p Principal) CanModify(ownerID string) bool {
+	return p.IsAdmin() || (ownerID != "" && p.ID == ownerID)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the caller stored by Require, if any.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// APIKeys authenticates requests carrying a static key, either as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>". Only hashes of the
+// keys are kept in memory.
+type APIKeys struct {
+	keys map[[sha256.Size]byte]Principal
+}
+
+// ParseAPIKeys reads a comma-separated list of key:user-id[:role] entries,
+// e.g. "s3cret:alice:admin,0ther:bob". The role defaults to user.
+func ParseAPIKeys(spec string) (*APIKeys, error) {
+	a := &APIKeys{keys: map[[sha256.Size]byte]Principal{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("api key entry %q: want key:user-id[:role]", redact(entry))
+		}
+		p := Principal{ID: parts[1], Role: RoleUser}
+		if len(parts) == 3 {
+			p.Role = Role(parts[2])
+			if p.Role != RoleUser && p.Role != RoleAdmin {
+				return nil, fmt.Errorf("api key for %s: unknown role %q", p.ID, parts[2])
+			}
+		}
+		a.keys[sha256.Sum256([]byte(parts[0]))] = p
+	}
+	return a, nil
+}
+
+// Len returns the number of configured keys.
+func (a *APIKeys) Len() int {
+	if a == nil {
+		return 0
+	}
+	return len(a.keys)
+}
+
+// Authenticate returns the principal for the request's key.
+func (a *APIKeys) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = strings.TrimSpace(bearer)
+	}
+	if key == "" {
+		return Principal{}, fmt.Errorf("%w: missing credentials", response.ErrUnauthorized)
+	}
+	if a != nil {
+		if p, ok := a.keys[sha256.Sum256([]byte(key))]; ok {
+			return p, nil
+		}
+	}
+	return Principal{}, fmt.Errorf("%w: unknown API key", response.ErrUnauthorized)
+}
+
+// Require rejects unauthenticated requests with 401 and stores the caller's
+// Principal in the context of those it lets through.
+func Require(a *APIKeys, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="restful-book"`)
+			response.Error(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+func redact(entry string) string {
+	_, rest, _ := strings.Cut(entry, ":")
+	return "***:" + rest
+}
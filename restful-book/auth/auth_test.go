@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys(" k1:alice , k2:root:admin,,k3:bob:user ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", keys.Len())
+	}
+	if (*APIKeys)(nil).Len() != 0 {
+		t.Fatal("nil APIKeys has keys")
+	}
+
+	for _, spec := range []string{"k1", "k1:", ":alice", "k1:alice:admin:extra", "k1:alice:root"} {
+		_, err := ParseAPIKeys("s3cret:x," + spec)
+		if err == nil {
+			t.Errorf("ParseAPIKeys(%q) succeeded", spec)
+		} else if strings.Contains(err.Error(), "s3cret") || (strings.HasPrefix(spec, "k1:") && strings.Contains(err.Error(), "k1")) {
+			t.Errorf("ParseAPIKeys(%q) error leaks the key: %v", spec, err)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	keys, err := ParseAPIKeys("k1:alice,k2:root:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		want    Principal
+		wantErr bool
+	}{
+		{"missing", nil, Principal{}, true},
+		{"X-API-Key", map[string]string{"X-API-Key": "k1"}, Principal{ID: "alice", Role: RoleUser}, false},
+		{"Bearer", map[string]string{"Authorization": "Bearer k2"}, Principal{ID: "root", Role: RoleAdmin}, false},
+		{"Bearer with spaces", map[string]string{"Authorization": "Bearer  k1 "}, Principal{ID: "alice", Role: RoleUser}, false},
+		{"Bearer wins", map[string]string{"Authorization": "Bearer k2", "X-API-Key": "k1"}, Principal{ID: "root", Role: RoleAdmin}, false},
+		{"unknown", map[string]string{"X-API-Key": "k3"}, Principal{}, true},
+		{"empty bearer", map[string]string{"Authorization": "Bearer "}, Principal{}, true},
+		{"other scheme", map[string]string{"Authorization": "Basic azE6"}, Principal{}, true},
+		{"lower-case scheme", map[string]string{"Authorization": "bearer k1"}, Principal{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			p, err := keys.Authenticate(r)
+			if tc.wantErr {
+				if !errors.Is(err, response.ErrUnauthorized) {
+					t.Fatalf("Authenticate = %+v, %v, want ErrUnauthorized", p, err)
+				}
+				return
+			}
+			if err != nil || p != tc.want {
+				t.Fatalf("Authenticate = %+v, %v, want %+v", p, err, tc.want)
+			}
+		})
+	}
+
+	var none *APIKeys
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-API-Key", "k1")
+	if _, err := none.Authenticate(r); !errors.Is(err, response.ErrUnauthorized) {
+		t.Fatalf("Authenticate without keys = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCanModify(t *testing.T) {
+	alice := Principal{ID: "alice", Role: RoleUser}
+	admin := Principal{ID: "root", Role: RoleAdmin}
+	for _, tc := range []struct {
+		p     Principal
+		owner string
+		want  bool
+	}{
+		{alice, "alice", true},
+		{alice, "bob", false},
+		// Ownerless records predate authentication and are admin-only.
+		{alice, "", false},
+		{Principal{}, "", false},
+		{admin, "alice", true},
+		{admin, "", true},
+	} {
+		if got := tc.p.CanModify(tc.owner); got != tc.want {
+			t.Errorf("%+v.CanModify(%q) = %v, want %v", tc.p, tc.owner, got, tc.want)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	keys, err := ParseAPIKeys("k1:alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Require(keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := PrincipalFrom(r.Context())
+		if !ok || p.ID != "alice" {
+			t.Errorf("PrincipalFrom = %+v, %v", p, ok)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("no key: %d, WWW-Authenticate %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	rec = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-API-Key", "k1")
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("valid key: %d", rec.Code)
+	}
+}
@@ -0,0 +1,22 @@
+// Package blob stores opaque binary objects such as cover images under
+// slash-separated keys.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned for a key with no object.
+var ErrNotFound = errors.New("blob: not found")
+
+// Store keeps objects by key. Put replaces any existing object atomically,
+// so readers see either the old or the new content, never a mix.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the object for reading; the caller must close it.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes the object; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
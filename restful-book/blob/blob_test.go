@@ -0,0 +1,59 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStores(t *testing.T) {
+	files, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]Store{"file": files, "memory": NewMemoryStore()}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			const key = "covers/a/cover"
+			if err := s.Put(ctx, key, strings.NewReader("old")); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Put(ctx, key, strings.NewReader("new")); err != nil {
+				t.Fatal(err)
+			}
+			f, err := s.Open(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil || string(data) != "new" {
+				t.Fatalf("Open after replace = %q, %v; want %q", data, err, "new")
+			}
+
+			for range 2 {
+				if err := s.Delete(ctx, key); err != nil {
+					t.Fatalf("Delete: %v", err)
+				}
+			}
+			if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Open after Delete: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestFileStoreRejectsEscapingKeys(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "../x", "/x", "a/../../x", "a//b"} {
+		if err := s.Put(context.Background(), key, strings.NewReader("")); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+}
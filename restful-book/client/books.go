@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/patch"
+)
+
+const booksPath = "/api/books"
+
+// ListBooks returns one page of the books matching q.
+func (c *Client) ListBooks(ctx context.Context, q models.BookQuery) (*models.BookPage, error) {
+	var books []*models.Book
+	meta, err := c.do(ctx, request{method: http.MethodGet, path: booksPath, query: bookQuery(q)}, &books)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.BookPage{Books: books, Total: len(books)}
+	if meta != nil {
+		page.Total, page.Limit, page.Offset = meta.Total, meta.Limit, meta.Offset
+	}
+	return page, nil
+}
+
+// GetBookByID returns the book with the given id.
+func (c *Client) GetBookByID(ctx context.Context, id string) (*models.Book, error) {
+	var book models.Book
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: bookPath(id)}, &book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+// CreateBook creates book and overwrites it with the stored book, so its
+// ID, Version and OwnerID are filled in. It is not retried after network
+// errors, which could create the book twice.
+func (c *Client) CreateBook(ctx context.Context, book *models.Book) error {
+	body, err := json.Marshal(book)
+	if err != nil {
+		return err
+	}
+	req := request{method: http.MethodPost, path: booksPath, body: body, ctype: "application/json"}
+	var created models.Book
+	if _, err := c.do(ctx, req, &created); err != nil {
+		return err
+	}
+	*book = created
+	return nil
+}
+
+// UpdateBook replaces the book with the given id. A non-zero book.Version
+// is sent as If-Match, so the update fails with ErrVersionMismatch if the
+// book changed since it was read.
+func (c *Client) UpdateBook(ctx context.Context, id string, book models.Book) (*models.Book, error) {
+	body, err := json.Marshal(book)
+	if err != nil {
+		return nil, err
+	}
+	req := request{method: http.MethodPut, path: bookPath(id), body: body, ctype: "application/json", ifMatch: book.Version}
+	var updated models.Book
+	if _, err := c.do(ctx, req, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// PatchBook applies an RFC 7396 merge patch to the book with the given id.
+// A non-zero version must match the stored version.
+func (c *Client) PatchBook(ctx context.Context, id string, mergePatch []byte, version int) (*models.Book, error) {
+	req := request{method: http.MethodPatch, path: bookPath(id), body: mergePatch, ctype: patch.MergePatchContentType, ifMatch: version}
+	var patched models.Book
+	if _, err := c.do(ctx, req, &patched); err != nil {
+		return nil, err
+	}
+	return &patched, nil
+}
+
+// DeleteBook moves the book with the given id to the trash. A non-zero
+// version must match the stored version.
+func (c *Client) DeleteBook(ctx context.Context, id string, version int) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: bookPath(id), ifMatch: version}, nil)
+	return err
+}
+
+// SearchBooks runs a full-text search over title, author and description,
+// returning at most limit books ranked by relevance; zero means the
+// server's default limit.
+func (c *Client) SearchBooks(ctx context.Context, query string, limit int) ([]*models.Book, error) {
+	q := url.Values{"q": {query}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var books []*models.Book
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: booksPath + "/search", query: q}, &books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func bookPath(id string) string {
+	return booksPath + "/" + url.PathEscape(id)
+}
+
+// bookQuery encodes the non-zero fields of q as the query parameters of
+// GET /api/books.
+func bookQuery(q models.BookQuery) url.Values {
+	values := url.Values{}
+	for name, v := range map[string]string{
+		"title":       q.Title,
+		"author":      q.Author,
+		"author_id":   q.AuthorID,
+		"isbn_prefix": q.ISBNPrefix,
+	} {
+		if v != "" {
+			values.Set(name, v)
+		}
+	}
+	for name, n := range map[string]int{
+		"year_from": q.YearFrom,
+		"year_to":   q.YearTo,
+		"limit":     q.Limit,
+		"offset":    q.Offset,
+	} {
+		if n != 0 {
+			values.Set(name, strconv.Itoa(n))
+		}
+	}
+	if len(q.Sort) > 0 {
+		fields := make([]string, len(q.Sort))
+		for i, s := range q.Sort {
+			fields[i] = s.Field
+			if s.Desc {
+				fields[i] = "-" + s.Field
+			}
+		}
+		values.Set("sort", strings.Join(fields, ","))
+	}
+	return values
+}
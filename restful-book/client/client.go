@@ -0,0 +1,246 @@
+// Package client is a Go client for the books API. Its methods mirror
+// service.BookService, and the errors it returns unwrap to the
+// domain/response sentinels the server reported, so callers can write
+//
+//	book, err := c.GetBookByID(ctx, id)
+//	if errors.Is(err, response.ErrBookNotFound) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// Defaults for the zero values of Options.
+const (
+	DefaultRetries = 3
+	DefaultBackoff = 200 * time.Millisecond
+	// maxBackoff caps the wait between retries, both the doubling backoff
+	// and the one a Retry-After header asks for.
+	maxBackoff = 5 * time.Second
+)
+
+// Options configures New.
+type Options struct {
+	// APIKey authenticates the caller; it is sent as a bearer token. Reads
+	// work without one.
+	APIKey string
+	// HTTPClient sends the requests. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Retries is how many times a request is repeated after a network
+	// error or a 502, 503 or 504 response, which only happens for
+	// idempotent methods, or after a 429, which the server sends before
+	// doing anything. Zero means DefaultRetries and a negative value
+	// disables retries.
+	Retries int
+	// Backoff is the wait before the first retry; it doubles for each
+	// further one. A Retry-After header takes precedence, but no wait
+	// exceeds five seconds. Zero means DefaultBackoff.
+	Backoff time.Duration
+}
+
+// Client calls the books API at one base URL. It is safe for concurrent use.
+type Client struct {
+	base    *url.URL
+	apiKey  string
+	http    *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// New returns a client for the API served at baseURL, e.g.
+// "http://localhost:8081".
+func New(baseURL string, opts Options) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("client: base URL %q is not an absolute http or https URL", baseURL)
+	}
+	c := &Client{base: base, apiKey: opts.APIKey, http: opts.HTTPClient, retries: opts.Retries, backoff: opts.Backoff}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	if c.retries == 0 {
+		c.retries = DefaultRetries
+	}
+	c.retries = max(c.retries, 0)
+	if c.backoff <= 0 {
+		c.backoff = DefaultBackoff
+	}
+	return c, nil
+}
+
+// Error is an error response from the API. It unwraps to the
+// domain/response sentinel named by Code and, for a validation failure, to
+// the sentinels of the failed fields, so errors.Is works on it as it does
+// on the server.
+type Error struct {
+	StatusCode int
+	// Code is the machine-readable error code, empty if the response was
+	// not an API error envelope, e.g. one from a proxy.
+	Code    string
+	Message string
+	Fields  []response.FieldError
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	for _, f := range e.Fields {
+		msg += "; " + f.Field + ": " + f.Message
+	}
+	return fmt.Sprintf("restful-book: %d %s", e.StatusCode, msg)
+}
+
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if err := response.FromCode(e.Code); err != nil {
+		errs = append(errs, err)
+	}
+	for _, f := range e.Fields {
+		if err := response.FromCode(f.Code); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// request describes one API call.
+type request struct {
+	method  string
+	path    string // escaped, relative to the base URL
+	query   url.Values
+	body    []byte
+	ctype   string
+	ifMatch int // sent as If-Match when non-zero
+}
+
+// envelope is the client side of response.StandardResponse.
+type envelope struct {
+	Data    json.RawMessage       `json:"data"`
+	Meta    *response.Meta        `json:"meta"`
+	Message string                `json:"message"`
+	Code    string                `json:"code"`
+	Error   string                `json:"error"`
+	Errors  []response.FieldError `json:"errors"`
+}
+
+// do sends req, retrying as Options.Retries describes, and decodes the data
+// of a successful response into data unless it is nil. It returns the meta
+// of a paginated response.
+func (c *Client) do(ctx context.Context, req request, data any) (*response.Meta, error) {
+	idempotent := req.method != http.MethodPost && req.method != http.MethodPatch
+	wait := c.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req)
+		retry := false
+		switch {
+		case err != nil:
+			retry = idempotent && ctx.Err() == nil
+		case resp.StatusCode == http.StatusTooManyRequests:
+			retry = true
+		case resp.StatusCode == http.StatusBadGateway, resp.StatusCode == http.StatusServiceUnavailable,
+			resp.StatusCode == http.StatusGatewayTimeout:
+			retry = idempotent
+		}
+		if !retry || attempt >= c.retries {
+			if err != nil {
+				return nil, err
+			}
+			return decode(resp, data)
+		}
+
+		delay := wait
+		if resp != nil {
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+				// Capping the seconds before converting them also keeps
+				// a huge value from overflowing into a negative delay.
+				delay = time.Duration(min(s, int(maxBackoff/time.Second))) * time.Second
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		wait = min(2*wait, maxBackoff)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	u := *c.base
+	// Set both forms of the path so an escaped "/" in an ID stays escaped.
+	u.RawPath = u.EscapedPath() + req.path
+	path, err := url.PathUnescape(u.RawPath)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path
+	u.RawQuery = req.query.Encode()
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	r, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Accept", "application/json")
+	if req.body != nil {
+		r.Header.Set("Content-Type", req.ctype)
+	}
+	if req.ifMatch != 0 {
+		r.Header.Set("If-Match", `"`+strconv.Itoa(req.ifMatch)+`"`)
+	}
+	if c.apiKey != "" {
+		r.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return c.http.Do(r)
+}
+
+// decode reads the envelope of resp, returning an *Error for a non-2xx
+// status.
+func decode(resp *http.Response, data any) (*response.Meta, error) {
+	defer resp.Body.Close()
+	var env envelope
+	decodeErr := json.NewDecoder(resp.Body).Decode(&env)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		if decodeErr == nil {
+			apiErr.Code, apiErr.Fields = env.Code, env.Errors
+			if env.Error != "" {
+				apiErr.Message = env.Error
+			}
+		}
+		return nil, apiErr
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("client: decode response: %w", decodeErr)
+	}
+	if data != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, data); err != nil {
+			return nil, fmt.Errorf("client: decode response data: %w", err)
+		}
+	}
+	return env.Meta, nil
+}
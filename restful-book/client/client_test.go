@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/api"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	keys, err := auth.ParseAPIKeys("k1:alice,k2:bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := api.NewRouter(repository.NewBookRepository(), api.Options{APIKeys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newClient(t *testing.T, baseURL string, opts Options) *Client {
+	t.Helper()
+	c, err := New(baseURL, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNewRejectsRelativeURL(t *testing.T) {
+	for _, u := range []string{"localhost:8081", "/api", "ftp://example.com"} {
+		if _, err := New(u, Options{}); err == nil {
+			t.Errorf("New(%q) succeeded", u)
+		}
+	}
+}
+
+func TestBooks(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	c := newClient(t, srv.URL+"/", Options{APIKey: "k1"})
+
+	book := &models.Book{Title: "The Go Programming Language", Author: "Donovan", PublishedYear: 2015}
+	if err := c.CreateBook(ctx, book); err != nil {
+		t.Fatal(err)
+	}
+	if book.ID == "" || book.Version != 1 || book.OwnerID != "alice" {
+		t.Fatalf("CreateBook did not fill in the stored book: %+v", book)
+	}
+	if err := c.CreateBook(ctx, &models.Book{}); !errors.Is(err, response.ErrEmptyBookTitle) {
+		t.Fatalf("CreateBook(empty) = %v, want ErrEmptyBookTitle", err)
+	}
+
+	got, err := c.GetBookByID(ctx, book.ID)
+	if err != nil || got.Title != book.Title {
+		t.Fatalf("GetBookByID = %+v, %v", got, err)
+	}
+	if _, err := c.GetBookByID(ctx, "00000000-0000-0000-0000-000000000000"); !errors.Is(err, response.ErrBookNotFound) {
+		t.Fatalf("GetBookByID(missing) = %v, want ErrBookNotFound", err)
+	}
+	var apiErr *Error
+	if _, err := c.GetBookByID(ctx, "nope"); !errors.As(err, &apiErr) || !errors.Is(err, response.ErrInvalidBookID) {
+		t.Fatalf("GetBookByID(invalid) = %v, want ErrInvalidBookID", err)
+	}
+	if apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("GetBookByID(invalid) status = %d, want 422", apiErr.StatusCode)
+	}
+
+	got.Title = "The Go Programming Language, 2nd ed."
+	updated, err := c.UpdateBook(ctx, book.ID, *got)
+	if err != nil || updated.Version != 2 {
+		t.Fatalf("UpdateBook = %+v, %v", updated, err)
+	}
+	if _, err := c.UpdateBook(ctx, book.ID, *got); !errors.Is(err, response.ErrVersionMismatch) {
+		t.Fatalf("UpdateBook(stale) = %v, want ErrVersionMismatch", err)
+	}
+	patched, err := c.PatchBook(ctx, book.ID, []byte(`{"description":"go book"}`), 2)
+	if err != nil || patched.Description != "go book" {
+		t.Fatalf("PatchBook = %+v, %v", patched, err)
+	}
+
+	page, err := c.ListBooks(ctx, models.BookQuery{
+		Author: "Donovan",
+		Sort:   []models.SortField{{Field: "title", Desc: true}},
+		Limit:  5,
+	})
+	if err != nil || page.Total != 1 || page.Limit != 5 || len(page.Books) != 1 {
+		t.Fatalf("ListBooks = %+v, %v", page, err)
+	}
+	found, err := c.SearchBooks(ctx, "programming", 3)
+	if err != nil || len(found) != 1 {
+		t.Fatalf("SearchBooks = %v, %v", found, err)
+	}
+
+	bob := newClient(t, srv.URL, Options{APIKey: "k2"})
+	if err := bob.DeleteBook(ctx, book.ID, 0); !errors.Is(err, response.ErrForbidden) {
+		t.Fatalf("DeleteBook by another user = %v, want ErrForbidden", err)
+	}
+	if err := c.DeleteBook(ctx, book.ID, 3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls, failures atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		response.JSON(w, models.Book{ID: "x"}, "Success", http.StatusOK)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	c := newClient(t, srv.URL, Options{Backoff: time.Millisecond})
+
+	failures.Store(2)
+	if book, err := c.GetBookByID(ctx, "x"); err != nil || book.ID != "x" || calls.Load() != 3 {
+		t.Fatalf("GET after two 503s = %v, %v after %d calls", book, err, calls.Load())
+	}
+
+	calls.Store(0)
+	failures.Store(1)
+	if err := c.CreateBook(ctx, &models.Book{}); err == nil || calls.Load() != 1 {
+		t.Fatalf("POST was retried: %v after %d calls", err, calls.Load())
+	}
+
+	calls.Store(0)
+	failures.Store(100)
+	var apiErr *Error
+	if _, err := c.GetBookByID(ctx, "x"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("GET after exhausting retries = %v", err)
+	}
+	if calls.Load() != DefaultRetries+1 {
+		t.Fatalf("GET made %d calls, want %d", calls.Load(), DefaultRetries+1)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	slow := newClient(t, srv.URL, Options{Backoff: time.Hour})
+	if _, err := slow.GetBookByID(ctx, "x"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GET with a cancelled backoff = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestRetryAfterIsCapped(t *testing.T) {
+	t.Parallel()
+	var limited atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limited.CompareAndSwap(false, true) {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		response.JSON(w, models.Book{ID: "x"}, "Success", http.StatusOK)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*maxBackoff)
+	defer cancel()
+
+	c := newClient(t, srv.URL, Options{})
+	if book, err := c.GetBookByID(ctx, "x"); err != nil || book.ID != "x" {
+		t.Fatalf("GET after an hour-long Retry-After = %v, %v", book, err)
+	}
+}
+
+func TestEscapesIDsOnce(t *testing.T) {
+	paths := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.EscapedPath()
+		response.JSON(w, models.Book{ID: "x"}, "Success", http.StatusOK)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	for base, want := range map[string]string{
+		srv.URL:               "/api/books/a%2Fb%20c",
+		srv.URL + "/v%20one/": "/v%20one/api/books/a%2Fb%20c",
+	} {
+		c := newClient(t, base, Options{})
+		if _, err := c.GetBookByID(ctx, "a/b c"); err != nil {
+			t.Fatal(err)
+		}
+		if got := <-paths; got != want {
+			t.Errorf("GetBookByID(%q) against %s requested %s, want %s", "a/b c", base, got, want)
+		}
+	}
+}
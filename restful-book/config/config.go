@@ -0,0 +1,284 @@
+// Package config loads restful-book server settings from, in increasing
+// order of precedence: built-in defaults, an optional JSON file, BOOKS_*
+// environment variables and command-line flags.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Addr            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	Storage         string
+	DBPath          string
+	LogLevel        slog.Level
+	// TrashRetention is how long deleted books stay restorable before they
+	// are purged; zero keeps them forever.
+	TrashRetention time.Duration
+	// APIKeys lists key:user-id[:role] entries; see auth.ParseAPIKeys.
+	APIKeys string
+	// CacheTTL and CacheSize bound the book read cache; either being zero
+	// disables it.
+	CacheTTL  time.Duration
+	CacheSize int
+	// CoverDir is where the sqlite backend keeps cover images.
+	CoverDir string
+	// MaxBodyBytes caps request bodies except uploads, which have their own
+	// limits.
+	MaxBodyBytes int
+	// RateLimit is the sustained requests per second allowed per client IP,
+	// with bursts of up to RateBurst; zero disables rate limiting.
+	RateLimit float64
+	RateBurst int
+	// WebhookAttempts is how many times a webhook event is sent before it
+	// is dead-lettered; the wait between attempts starts at WebhookBackoff
+	// and doubles after each failure.
+	WebhookAttempts int
+	WebhookBackoff  time.Duration
+	// WebhookWorkers is how many webhook deliveries run at once.
+	WebhookWorkers int
+	// WebhookAllowPrivate lets webhooks target loopback, link-local and
+	// private addresses, e.g. receivers on the same host or network.
+	WebhookAllowPrivate bool
+	// EventBuffer is how many recent book events the event stream keeps
+	// for clients resuming with Last-Event-ID.
+	EventBuffer int
+}
+
+func Default() Config {
+	return Config{
+		Addr:            ":8081",
+		ReadTimeout:     10 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		IdleTimeout:     30 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+		Storage:         "memory",
+		DBPath:          "books.db",
+		LogLevel:        slog.LevelInfo,
+		TrashRetention:  30 * 24 * time.Hour,
+		CacheTTL:        30 * time.Second,
+		CacheSize:       1000,
+		CoverDir:        "covers",
+		MaxBodyBytes:    1 << 20,
+		RateLimit:       20,
+		RateBurst:       40,
+		WebhookAttempts: 8,
+		WebhookBackoff:  2 * time.Second,
+		WebhookWorkers:  16,
+		EventBuffer:     1000,
+	}
+}
+
+// setting binds one option to its file key, environment variable and flag.
+// Every source is parsed through set, so all of them accept the same syntax.
+type setting struct {
+	key   string
+	usage string
+	set   func(c *Config, value string) error
+	get   func(c Config) string
+}
+
+func settings() []setting {
+	return []setting{
+		{"addr", "listen address", setString(func(c *Config) *string { return &c.Addr }), func(c Config) string { return c.Addr }},
+		{"read-timeout", "maximum duration for reading a request", setDuration(func(c *Config) *time.Duration { return &c.ReadTimeout }), func(c Config) string { return c.ReadTimeout.String() }},
+		{"write-timeout", "maximum duration for writing a response", setDuration(func(c *Config) *time.Duration { return &c.WriteTimeout }), func(c Config) string { return c.WriteTimeout.String() }},
+		{"idle-timeout", "keep-alive idle timeout", setDuration(func(c *Config) *time.Duration { return &c.IdleTimeout }), func(c Config) string { return c.IdleTimeout.String() }},
+		{"shutdown-timeout", "how long to wait for in-flight requests on shutdown", setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout }), func(c Config) string { return c.ShutdownTimeout.String() }},
+		{"storage", "book storage backend: memory or sqlite", setString(func(c *Config) *string { return &c.Storage }), func(c Config) string { return c.Storage }},
+		{"db", "path to the SQLite database file", setString(func(c *Config) *string { return &c.DBPath }), func(c Config) string { return c.DBPath }},
+		{"log-level", "minimum log level: debug, info, warn or error", setLevel, func(c Config) string { return c.LogLevel.String() }},
+		{"trash-retention", "how long deleted books can be restored before they are purged; 0 keeps them forever", setDuration(func(c *Config) *time.Duration { return &c.TrashRetention }), func(c Config) string { return c.TrashRetention.String() }},
+		{"api-keys", "comma-separated key:user-id[:role] API keys for writes", setString(func(c *Config) *string { return &c.APIKeys }), func(c Config) string { return "" }},
+		{"cache-ttl", "how long book reads are cached; 0 disables the cache", setDuration(func(c *Config) *time.Duration { return &c.CacheTTL }), func(c Config) string { return c.CacheTTL.String() }},
+		{"cache-size", "maximum number of cached book reads; 0 disables the cache", setInt(func(c *Config) *int { return &c.CacheSize }), func(c Config) string { return strconv.Itoa(c.CacheSize) }},
+		{"max-body-size", "maximum request body size in bytes, uploads excepted", setInt(func(c *Config) *int { return &c.MaxBodyBytes }), func(c Config) string { return strconv.Itoa(c.MaxBodyBytes) }},
+		{"rate-limit", "requests per second allowed per client IP; 0 disables rate limiting", setFloat(func(c *Config) *float64 { return &c.RateLimit }), func(c Config) string { return strconv.FormatFloat(c.RateLimit, 'g', -1, 64) }},
+		{"rate-burst", "requests a client IP may make at once before the rate limit applies", setInt(func(c *Config) *int { return &c.RateBurst }), func(c Config) string { return strconv.Itoa(c.RateBurst) }},
+		{"webhook-attempts", "how many times a webhook event is sent before it is dead-lettered", setInt(func(c *Config) *int { return &c.WebhookAttempts }), func(c Config) string { return strconv.Itoa(c.WebhookAttempts) }},
+		{"webhook-backoff", "wait before the first webhook retry; doubles after each failure", setDuration(func(c *Config) *time.Duration { return &c.WebhookBackoff }), func(c Config) string { return c.WebhookBackoff.String() }},
+		{"webhook-workers", "how many webhook deliveries run at once", setInt(func(c *Config) *int { return &c.WebhookWorkers }), func(c Config) string { return strconv.Itoa(c.WebhookWorkers) }},
+		{"webhook-allow-private", "let webhooks target loopback, link-local and private addresses", setBool(func(c *Config) *bool { return &c.WebhookAllowPrivate }), func(c Config) string { return strconv.FormatBool(c.WebhookAllowPrivate) }},
+		{"event-buffer", "how many recent book events the event stream keeps for resuming clients", setInt(func(c *Config) *int { return &c.EventBuffer }), func(c Config) string { return strconv.Itoa(c.EventBuffer) }},
+		{"cover-dir", "directory for cover images with sqlite storage", setString(func(c *Config) *string { return &c.CoverDir }), func(c Config) string { return c.CoverDir }},
+	}
+}
+
+// envName maps a setting key such as "read-timeout" to BOOKS_READ_TIMEOUT.
+// The database path keeps its historical name, BOOKS_DB_PATH.
+func envName(key string) string {
+	if key == "db" {
+		return "BOOKS_DB_PATH"
+	}
+	return "BOOKS_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// Load builds the configuration for the command line args (without the
+// program name). The config file is named by -config or BOOKS_CONFIG.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+	all := settings()
+
+	fs := flag.NewFlagSet("restful-book", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("BOOKS_CONFIG"), "optional JSON config file")
+	flagValues := map[string]*string{}
+	for _, s := range all {
+		flagValues[s.key] = fs.String(s.key, s.get(cfg), s.usage+" (env "+envName(s.key)+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configPath != "" {
+		if err := loadFile(&cfg, *configPath, all); err != nil {
+			return cfg, err
+		}
+	}
+
+	for _, s := range all {
+		if v, ok := os.LookupEnv(envName(s.key)); ok {
+			if err := s.set(&cfg, v); err != nil {
+				return cfg, fmt.Errorf("%s: %w", envName(s.key), err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range all {
+			if s.key == f.Name && flagErr == nil {
+				if err := s.set(&cfg, *flagValues[s.key]); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", s.key, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
+	}
+
+	return cfg, cfg.validate()
+}
+
+// loadFile reads a flat JSON object whose keys are the flag names, e.g.
+// {"addr": ":9000", "read-timeout": "5s", "storage": "sqlite"}.
+func loadFile(cfg *Config, path string, all []setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+
+	known := map[string]setting{}
+	for _, s := range all {
+		known[s.key] = s
+	}
+	for key, v := range values {
+		s, ok := known[key]
+		if !ok {
+			return fmt.Errorf("config %s: unknown setting %q", path, key)
+		}
+		if err := s.set(cfg, v); err != nil {
+			return fmt.Errorf("config %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+func (c Config) validate() error {
+	switch c.Storage {
+	case "memory", "sqlite":
+	default:
+		return fmt.Errorf("unknown storage backend %q", c.Storage)
+	}
+	if c.Storage == "sqlite" && c.DBPath == "" {
+		return errors.New("sqlite storage needs a database path")
+	}
+	if c.Storage == "sqlite" && c.CoverDir == "" {
+		return errors.New("sqlite storage needs a cover directory")
+	}
+	if c.Addr == "" {
+		return errors.New("listen address cannot be empty")
+	}
+	return nil
+}
+
+func setString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func setDuration(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf("duration %s is negative", v)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("%d is negative", n)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setFloat(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		if f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%s is not a finite, non-negative number", v)
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func setBool(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setLevel(c *Config, v string) error {
+	return c.LogLevel.UnmarshalText([]byte(v))
+}
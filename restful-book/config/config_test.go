@@ -0,0 +1,102 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != Default() {
+		t.Fatalf("Load(nil) = %+v, want the defaults %+v", cfg, Default())
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	// Each layer sets one more option than the next and overrides the
+	// options the layers below it set.
+	path := writeFile(t, `{"addr": ":1", "read-timeout": "1s", "storage": "sqlite", "log-level": "debug"}`)
+	t.Setenv("BOOKS_CONFIG", path)
+	t.Setenv("BOOKS_ADDR", ":2")
+	t.Setenv("BOOKS_READ_TIMEOUT", "2s")
+	t.Setenv("BOOKS_DB_PATH", "env.db")
+
+	cfg, err := Load([]string{"-addr", ":3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.Addr = ":3"
+	want.ReadTimeout = 2 * time.Second
+	want.DBPath = "env.db"
+	want.Storage = "sqlite"
+	want.LogLevel = slog.LevelDebug
+	if cfg != want {
+		t.Fatalf("Load = %+v, want %+v", cfg, want)
+	}
+
+	// -config takes precedence over BOOKS_CONFIG.
+	other := writeFile(t, `{"write-timeout": "4s"}`)
+	cfg, err = Load([]string{"-config", other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.WriteTimeout != 4*time.Second || cfg.Storage != "memory" || cfg.Addr != ":2" {
+		t.Fatalf("Load(-config) = %+v", cfg)
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	for name, tc := range map[string]struct {
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		"bad flag duration":    {args: []string{"-read-timeout", "soon"}, want: "-read-timeout"},
+		"negative duration":    {args: []string{"-idle-timeout", "-1s"}, want: "negative"},
+		"unknown flag":         {args: []string{"-bogus"}, want: "bogus"},
+		"bad env duration":     {env: map[string]string{"BOOKS_WRITE_TIMEOUT": "10"}, want: "BOOKS_WRITE_TIMEOUT"},
+		"bad log level":        {env: map[string]string{"BOOKS_LOG_LEVEL": "loud"}, want: "BOOKS_LOG_LEVEL"},
+		"unknown file setting": {file: `{"adr": ":1"}`, want: `unknown setting "adr"`},
+		"malformed file":       {file: `{"addr":`, want: "parse config"},
+		"non-string value":     {file: `{"read-timeout": 5}`, want: "parse config"},
+		"unknown storage":      {args: []string{"-storage", "postgres"}, want: `unknown storage backend "postgres"`},
+		"sqlite without db":    {args: []string{"-storage", "sqlite", "-db", ""}, want: "database path"},
+		"empty addr":           {env: map[string]string{"BOOKS_ADDR": ""}, want: "listen address"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			args := tc.args
+			if tc.file != "" {
+				args = append([]string{"-config", writeFile(t, tc.file)}, args...)
+			}
+			_, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("Load = %v, want an error mentioning %q", err, tc.want)
+			}
+		})
+	}
+
+	if _, err := Load([]string{"-config", filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Fatal("Load with a missing config file succeeded")
+	}
+}
@@ -0,0 +1,10 @@
+package models
+
+type Author struct {
+	ID   string `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+	Bio  string `json:"bio" xml:"bio"`
+	// OwnerID is the ID of the user who created the author. Only the owner
+	// or an admin may change or delete it.
+	OwnerID string `json:"owner_id" xml:"owner_id"`
+}
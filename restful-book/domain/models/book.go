@@ -1,10 +1,46 @@
 package models
 
+import (
+	"slices"
+	"time"
+)
+
 type Book struct {
-	ID            string `json:"id"`
-	Title         string `json:"title"`
-	Author        string `json:"author"`
-	PublishedYear int    `json:"published_year"`
-	ISBN          string `json:"isbn"`
-	Description   string `json:"description"`
+	ID            string `json:"id" xml:"id"`
+	Title         string `json:"title" xml:"title"`
+	Author        string `json:"author" xml:"author"`
+	PublishedYear int    `json:"published_year" xml:"published_year"`
+	ISBN          string `json:"isbn" xml:"isbn"`
+	Description   string `json:"description" xml:"description"`
+	// AuthorIDs links the book to Author records in credit order; Author
+	// stays the display name as printed on the book.
+	AuthorIDs []string `json:"author_ids,omitempty" xml:"author_ids,omitempty"`
+	// Version starts at 1 and is incremented by the repository on every
+	// update; it backs the ETag of the book resource.
+	Version int `json:"version" xml:"version"`
+	// OwnerID is the ID of the user who created the book. Only the owner or
+	// an admin may change or delete it.
+	OwnerID string `json:"owner_id" xml:"owner_id"`
+	// DeletedAt is set while the book is in the trash. Trashed books are
+	// hidden from every read except the trash listing until they are
+	// restored or purged.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+	// Cover is set once a cover image has been uploaded. Like the owner it
+	// is managed by the server and ignored in create, update and patch
+	// bodies.
+	Cover *Cover `json:"cover,omitempty" xml:"cover,omitempty"`
+}
+
+// SameWork reports whether b and other are duplicates: the same title by the
+// same authors. Authors are compared by ID, in any credit order, when either
+// book links to author records, since one author can be printed in several
+// ways and two authors can share a name; otherwise by the Author text.
+func (b Book) SameWork(other Book) bool {
+	if b.Title != other.Title {
+		return false
+	}
+	if len(b.AuthorIDs) == 0 && len(other.AuthorIDs) == 0 {
+		return b.Author == other.Author
+	}
+	return slices.Equal(slices.Sorted(slices.Values(b.AuthorIDs)), slices.Sorted(slices.Values(other.AuthorIDs)))
 }
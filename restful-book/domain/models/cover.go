@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Cover describes the cover image of a book. The image and its thumbnail
+// are kept in a blob store under keys derived from the book ID and SHA256.
+type Cover struct {
+	ContentType string    `json:"content_type" xml:"content_type"`
+	Size        int64     `json:"size" xml:"size"`
+	Width       int       `json:"width" xml:"width"`
+	Height      int       `json:"height" xml:"height"`
+	SHA256      string    `json:"sha256" xml:"sha256"`
+	UpdatedAt   time.Time `json:"updated_at" xml:"updated_at"`
+}
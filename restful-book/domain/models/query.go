@@ -0,0 +1,90 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// BookSortFields lists the fields a BookQuery may be sorted by.
+var BookSortFields = map[string]bool{
+	"title":          true,
+	"author":         true,
+	"published_year": true,
+	"isbn":           true,
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// BookQuery selects a page of books. Zero-valued filters match everything;
+// all non-zero filters must match. A Limit of zero means no limit.
+type BookQuery struct {
+	Title      string
+	Author     string
+	AuthorID   string
+	YearFrom   int
+	YearTo     int
+	ISBNPrefix string
+
+	Sort   []SortField
+	Limit  int
+	Offset int
+}
+
+// BookPage is one page of a BookQuery result.
+type BookPage struct {
+	Books  []*Book
+	Total  int
+	Limit  int
+	Offset int
+}
+
+// Matches reports whether book satisfies every filter in q.
+func (q BookQuery) Matches(book Book) bool {
+	if q.Title != "" && book.Title != q.Title {
+		return false
+	}
+	if q.Author != "" && book.Author != q.Author {
+		return false
+	}
+	if q.AuthorID != "" && !slices.Contains(book.AuthorIDs, q.AuthorID) {
+		return false
+	}
+	if q.YearFrom != 0 && book.PublishedYear < q.YearFrom {
+		return false
+	}
+	if q.YearTo != 0 && book.PublishedYear > q.YearTo {
+		return false
+	}
+	if q.ISBNPrefix != "" && !strings.HasPrefix(book.ISBN, q.ISBNPrefix) {
+		return false
+	}
+	return true
+}
+
+// Compare orders a and b by q.Sort, returning a negative number, zero or a
+// positive number like strings.Compare.
+func (q BookQuery) Compare(a, b Book) int {
+	for _, s := range q.Sort {
+		var c int
+		switch s.Field {
+		case "title":
+			c = strings.Compare(a.Title, b.Title)
+		case "author":
+			c = strings.Compare(a.Author, b.Author)
+		case "published_year":
+			c = a.PublishedYear - b.PublishedYear
+		case "isbn":
+			c = strings.Compare(a.ISBN, b.ISBN)
+		}
+		if s.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
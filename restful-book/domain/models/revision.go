@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Actions recorded in a Revision.
+const (
+	ActionCreated  = "created"
+	ActionUpdated  = "updated"
+	ActionDeleted  = "deleted"
+	ActionRestored = "restored"
+	ActionReverted = "reverted"
+)
+
+// Revision is one entry in a book's append-only change log.
+type Revision struct {
+	BookID string `json:"book_id" xml:"book_id"`
+	// Revision is the Version the book had after the change.
+	Revision  int       `json:"revision" xml:"revision"`
+	Action    string    `json:"action" xml:"action"`
+	Actor     string    `json:"actor" xml:"actor"`
+	RequestID string    `json:"request_id,omitempty" xml:"request_id,omitempty"`
+	Timestamp time.Time `json:"timestamp" xml:"timestamp"`
+	// RevertedFrom is the revision whose content a revert restored.
+	RevertedFrom int           `json:"reverted_from,omitempty" xml:"reverted_from,omitempty"`
+	Changes      []FieldChange `json:"changes" xml:"changes"`
+	// Book is the state of the book after the change.
+	Book Book `json:"book" xml:"book"`
+}
+
+// FieldChange holds the JSON values of one field before and after a change.
+type FieldChange struct {
+	Field  string          `json:"field" xml:"field"`
+	Before json.RawMessage `json:"before" xml:"before"`
+	After  json.RawMessage `json:"after" xml:"after"`
+}
+
+// DiffBooks lists the fields that differ between before and after, in struct
+// order. The version is left out: it changes every time and is the
+// revision number itself.
+func DiffBooks(before, after Book) []FieldChange {
+	changes := []FieldChange{}
+	bv, av := reflect.ValueOf(before), reflect.ValueOf(after)
+	for i := range bv.NumField() {
+		name, _, _ := strings.Cut(bv.Type().Field(i).Tag.Get("json"), ",")
+		if name == "version" {
+			continue
+		}
+		b, _ := json.Marshal(bv.Field(i).Interface())
+		a, _ := json.Marshal(av.Field(i).Interface())
+		if string(b) != string(a) {
+			changes = append(changes, FieldChange{Field: name, Before: b, After: a})
+		}
+	}
+	return changes
+}
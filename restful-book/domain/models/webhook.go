@@ -0,0 +1,61 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Event types delivered to webhooks.
+const (
+	EventBookCreated = "book.created"
+	EventBookUpdated = "book.updated"
+	EventBookDeleted = "book.deleted"
+)
+
+// EventTypes lists every event type a webhook can subscribe to.
+var EventTypes = []string{EventBookCreated, EventBookUpdated, EventBookDeleted}
+
+// Webhook is a subscriber URL that is sent book events as signed POST
+// requests.
+type Webhook struct {
+	ID  string `json:"id" xml:"id"`
+	URL string `json:"url" xml:"url"`
+	// Events lists the event types the webhook receives; empty means all.
+	Events []string `json:"events" xml:"events"`
+	// Secret keys the HMAC signature of every delivery. It is issued when
+	// the webhook is created and only shown in that response.
+	Secret string `json:"secret,omitempty" xml:"secret,omitempty"`
+	// OwnerID is the ID of the user who registered the webhook. Only the
+	// owner or an admin may see or delete it.
+	OwnerID   string    `json:"owner_id" xml:"owner_id"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+}
+
+// Wants reports whether the webhook subscribes to events of eventType.
+func (w Webhook) Wants(eventType string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+// Event is the body of a webhook delivery.
+type Event struct {
+	// ID is the same for every attempt at delivering the event, so
+	// receivers can discard repeats.
+	ID        string    `json:"id" xml:"id"`
+	Type      string    `json:"type" xml:"type"`
+	Timestamp time.Time `json:"timestamp" xml:"timestamp"`
+	// Book is the state of the book after the change; a deleted book is
+	// sent as it was moved to the trash.
+	Book Book `json:"book" xml:"book"`
+}
+
+// DeadLetter is an event a webhook could not be sent in any of the allowed
+// attempts.
+type DeadLetter struct {
+	ID        string `json:"id" xml:"id"`
+	WebhookID string `json:"webhook_id" xml:"webhook_id"`
+	Event     Event  `json:"event" xml:"event"`
+	Attempts  int    `json:"attempts" xml:"attempts"`
+	// LastError describes why the final attempt failed.
+	LastError string    `json:"last_error" xml:"last_error"`
+	FailedAt  time.Time `json:"failed_at" xml:"failed_at"`
+}
@@ -1,11 +1,201 @@
 package response
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Kind classifies an error for the purpose of choosing an HTTP status.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindUnsupportedMediaType
+	KindPreconditionFailed
+	KindUnauthorized
+	KindForbidden
+	KindNotAcceptable
+	KindTooLarge
+	KindTooManyRequests
+	KindMethodNotAllowed
+)
+
+// Status returns the HTTP status code for errors of kind k.
+func (k Kind) Status() int {
+	switch k {
+	case KindBadRequest:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindValidation:
+		return http.StatusUnprocessableEntity
+	case KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotAcceptable:
+		return http.StatusNotAcceptable
+	case KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case KindTooManyRequests:
+		return http.StatusTooManyRequests
+	case KindMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// AppError is a sentinel error carrying its Kind and a stable,
+// machine-readable Code that clients can switch on.
+type AppError struct {
+	Kind Kind
+	Code string
+	Msg  string
+}
+
+func (e *AppError) Error() string {
+	return e.Msg
+}
+
+// byCode indexes the sentinels below by Code.
+var byCode = map[string]*AppError{}
+
+func newError(kind Kind, code, msg string) error {
+	e := &AppError{Kind: kind, Code: code, Msg: msg}
+	byCode[code] = e
+	return e
+}
+
+// FromCode returns the sentinel error with the given Code, or nil if there
+// is none. It lets clients turn the code of an error response back into the
+// error the server returned.
+func FromCode(code string) error {
+	if e, ok := byCode[code]; ok {
+		return e
+	}
+	return nil
+}
 
 var (
-	ErrBookNotFound     = errors.New("book not found")
-	ErrBookAlreadyExist = errors.New("book already exists")
-	ErrInvalidBookID    = errors.New("invalid book ID")
-	ErrEmptyBookTitle   = errors.New("book title cannot be empty")
-	ErrNoBooks          = errors.New("no books available")
+	ErrBookNotFound     = newError(KindNotFound, "book_not_found", "book not found")
+	ErrBookAlreadyExist = newError(KindConflict, "book_already_exists", "book already exists")
+	ErrVersionMismatch  = newError(KindPreconditionFailed, "version_mismatch", "book has been modified since it was read")
+	ErrInvalidBookID    = newError(KindValidation, "invalid_book_id", "invalid book ID")
+	ErrEmptyBookTitle   = newError(KindValidation, "empty_title", "book title cannot be empty")
+	ErrNoBooks          = newError(KindNotFound, "no_books", "no books available")
+	ErrRevisionNotFound = newError(KindNotFound, "revision_not_found", "revision not found")
+	ErrCoverNotFound    = newError(KindNotFound, "cover_not_found", "book has no cover image")
+	ErrCoverTooLarge    = newError(KindTooLarge, "cover_too_large", "cover image is too large")
+	ErrInvalidImage     = newError(KindValidation, "invalid_image", "cover is not a readable image")
+	ErrAuthorNotFound   = newError(KindNotFound, "author_not_found", "author not found")
+	ErrAuthorExists     = newError(KindConflict, "author_already_exists", "an author with this name already exists")
+	ErrAuthorHasBooks   = newError(KindConflict, "author_has_books", "author is still credited on books")
+	ErrInvalidAuthorID  = newError(KindValidation, "invalid_author_id", "invalid author ID")
+	ErrUnknownAuthor    = newError(KindValidation, "unknown_author", "no author with this ID")
+	ErrEmptyAuthorName  = newError(KindValidation, "empty_name", "author name cannot be empty")
+	ErrTooManyAuthors   = newError(KindValidation, "too_many_authors", "too many authors")
+	ErrDuplicateAuthor  = newError(KindValidation, "duplicate_author", "author is listed more than once")
+	ErrWebhookNotFound  = newError(KindNotFound, "webhook_not_found", "webhook not found")
+	ErrInvalidWebhookID = newError(KindValidation, "invalid_webhook_id", "invalid webhook ID")
+	ErrInvalidURL       = newError(KindValidation, "invalid_url", "URL must be an absolute http or https URL")
+	ErrUnknownEvent     = newError(KindValidation, "unknown_event", "no such event type")
+	ErrDuplicateEvent   = newError(KindValidation, "duplicate_event", "event type is listed more than once")
+	ErrUnauthorized     = newError(KindUnauthorized, "unauthorized", "authentication required")
+	ErrForbidden        = newError(KindForbidden, "forbidden", "only the owner or an admin may make this change")
+	ErrInvalidQuery     = newError(KindBadRequest, "invalid_query", "invalid query parameters")
+	ErrInvalidEventID   = newError(KindBadRequest, "invalid_event_id", "Last-Event-ID is not an event ID from this API")
+	ErrInvalidBody      = newError(KindBadRequest, "invalid_body", "invalid request body")
+	ErrBodyTooLarge     = newError(KindTooLarge, "body_too_large", "request body is too large")
+	ErrRateLimited      = newError(KindTooManyRequests, "rate_limited", "too many requests")
+	ErrInvalidPatch     = newError(KindBadRequest, "invalid_patch", "invalid merge patch")
+	ErrNotFound         = newError(KindNotFound, "not_found", "no such resource")
+	ErrMethodNotAllowed = newError(KindMethodNotAllowed, "method_not_allowed", "method not allowed for this resource")
+	ErrUnsupportedMedia = newError(KindUnsupportedMediaType, "unsupported_media_type", "unsupported content type")
+	ErrNotAcceptable    = newError(KindNotAcceptable, "not_acceptable", "none of the accepted response types is supported")
+	ErrEmptyBookAuthor  = newError(KindValidation, "empty_author", "book author cannot be empty")
+	ErrFieldTooLong     = newError(KindValidation, "too_long", "value is too long")
+	ErrInvalidYear      = newError(KindValidation, "invalid_year", "published year is out of range")
+	ErrInvalidISBN      = newError(KindValidation, "invalid_isbn", "invalid ISBN-10 or ISBN-13")
 )
+
+// StatusCode maps err to an HTTP status: validation failures are 422,
+// AppErrors use their Kind, and anything unrecognised is a 500.
+func StatusCode(err error) int {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return http.StatusUnprocessableEntity
+	}
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.Kind.Status()
+	}
+	return http.StatusInternalServerError
+}
+
+// Code returns the machine-readable code for err, "internal" if it has none.
+func Code(err error) string {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return "validation_failed"
+	}
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return "internal"
+}
+
+// FieldError describes why a single field of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Code    string `json:"code" xml:"code"`
+	Message string `json:"message" xml:"message"`
+	err     error
+}
+
+// ValidationError collects every field that failed validation. It unwraps to
+// the per-field errors, so errors.Is(err, ErrEmptyBookTitle) works on it.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Add records a failed field; err's text becomes the field message.
+func (v *ValidationError) Add(field string, err error) {
+	v.Fields = append(v.Fields, FieldError{Field: field, Code: Code(err), Message: err.Error(), err: err})
+}
+
+// Err returns v, or nil when no field failed.
+func (v *ValidationError) Err() error {
+	if len(v.Fields) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v *ValidationError) Error() string {
+	msgs := make([]string, len(v.Fields))
+	for i, f := range v.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (v *ValidationError) Unwrap() []error {
+	errs := make([]error, len(v.Fields))
+	for i, f := range v.Fields {
+		errs[i] = f.err
+	}
+	return errs
+}
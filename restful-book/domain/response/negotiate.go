@@ -0,0 +1,341 @@
+package response
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// codec reads and writes bodies in one media type.
+type codec interface {
+	contentType() string
+	encode(w io.Writer, v any) error
+	decode(r io.Reader, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) contentType() string             { return "application/json" }
+func (jsonCodec) encode(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) }
+
+// decode is strict: unknown fields and anything after the value are errors.
+func (jsonCodec) decode(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the JSON value")
+	}
+	return nil
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) contentType() string { return "application/xml" }
+
+func (xmlCodec) encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// decode is as strict as jsonCodec.decode. encoding/xml skips elements the
+// target has no field for and stops reading after the root element, so the
+// document is checked against the type of v before it is unmarshalled.
+func (xmlCodec) decode(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for root := false; ; {
+		tok, err := dec.Token()
+		if err == io.EOF && root {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if root {
+				return errors.New("unexpected data after the XML document")
+			}
+			root = true
+			if err := checkElements(dec, reflect.TypeOf(v)); err != nil {
+				return err
+			}
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) > 0 {
+				return errors.New("unexpected text outside the XML document")
+			}
+		}
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// checkElements reads up to the end of the element just started and fails
+// on any child element that t has no field for.
+func checkElements(dec *xml.Decoder, t reflect.Type) error {
+	for t.Kind() == reflect.Pointer || (t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8) {
+		t = t.Elem()
+	}
+	fields := xmlFields(t)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			field, ok := fields[tok.Name.Local]
+			if !ok {
+				return fmt.Errorf("unknown element <%s>", tok.Name.Local)
+			}
+			if field == nil {
+				if err := dec.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := checkElements(dec, field); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// xmlFields maps the child element names a struct is decoded from to the
+// types of their fields. Types that decode themselves, like time.Time, and
+// non-struct types have none. The children of the first element of an
+// "a>b" path are not checked, so it maps to nil.
+func xmlFields(t reflect.Type) map[string]reflect.Type {
+	p := reflect.PointerTo(t)
+	if t.Kind() != reflect.Struct || p.Implements(xmlUnmarshaler) || p.Implements(textUnmarshaler) {
+		return nil
+	}
+	fields := make(map[string]reflect.Type)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() || f.Name == "XMLName" {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("xml"), ",")
+		if name == "-" || strings.Contains(opts, "attr") || strings.Contains(opts, "chardata") ||
+			strings.Contains(opts, "innerxml") || strings.Contains(opts, "comment") {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if first, _, nested := strings.Cut(name, ">"); nested {
+			fields[first] = nil
+			continue
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+var (
+	xmlUnmarshaler  = reflect.TypeFor[xml.Unmarshaler]()
+	textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// msgpackCodec names fields after their json tags, so MessagePack bodies
+// have the same shape as JSON ones, and like JSON rejects unknown fields and
+// trailing data.
+type msgpackCodec struct{}
+
+func (msgpackCodec) contentType() string { return "application/msgpack" }
+
+func (msgpackCodec) encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+func (msgpackCodec) decode(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	// A bytes.Reader is read directly rather than through a buffer, so
+	// whatever the decoder leaves in it is trailing data.
+	rest := bytes.NewReader(data)
+	dec := msgpack.NewDecoder(rest)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(true)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if rest.Len() > 0 {
+		return errors.New("unexpected data after the MessagePack value")
+	}
+	return nil
+}
+
+// codecs lists the supported codecs in order of preference, each with the
+// media types that select it.
+var codecs = []struct {
+	codec      codec
+	mediaTypes []string
+}{
+	{jsonCodec{}, []string{"application/json"}},
+	{xmlCodec{}, []string{"application/xml", "text/xml"}},
+	{msgpackCodec{}, []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}},
+}
+
+const supportedTypes = "application/json, application/xml or application/msgpack"
+
+// Negotiate picks the response format from the Accept header and makes JSON,
+// JSONPage and Error write in it for the rest of the request. A request that
+// accepts none of the supported types gets 406 before next runs.
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		c, ok := negotiate(r.Header.Get("Accept"))
+		if !ok {
+			Error(w, fmt.Errorf("%w: use %s", ErrNotAcceptable, supportedTypes))
+			return
+		}
+		next.ServeHTTP(&codecWriter{ResponseWriter: w, codec: c}, r)
+	})
+}
+
+// Decode reads the request body into v in the format named by its
+// Content-Type; a body without one is read as JSON. A body cut short by
+// http.MaxBytesReader yields ErrBodyTooLarge.
+func Decode(r *http.Request, v any) error {
+	var c codec = jsonCodec{}
+	if header := r.Header.Get("Content-Type"); header != "" {
+		mediaType, _, err := mime.ParseMediaType(header)
+		if err == nil {
+			c = codecFor(mediaType)
+		}
+		if err != nil || c == nil {
+			return fmt.Errorf("%w: use %s", ErrUnsupportedMedia, supportedTypes)
+		}
+	}
+	if err := c.decode(r.Body, v); err != nil {
+		return BodyError(err)
+	}
+	return nil
+}
+
+// BodyError classifies a failure to read or parse a request body as
+// ErrBodyTooLarge or ErrInvalidBody.
+func BodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("%w: the limit is %d bytes", ErrBodyTooLarge, tooLarge.Limit)
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidBody, err)
+}
+
+func codecFor(mediaType string) codec {
+	for _, c := range codecs {
+		for _, t := range c.mediaTypes {
+			if t == mediaType {
+				return c.codec
+			}
+		}
+	}
+	return nil
+}
+
+// negotiate returns the codec the Accept header weighs highest, preferring
+// earlier codecs on a tie. A missing header accepts anything.
+func negotiate(accept string) (codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return jsonCodec{}, true
+	}
+	var best codec
+	bestQ := 0.0
+	for _, c := range codecs {
+		if q := quality(accept, c.mediaTypes); q > bestQ {
+			best, bestQ = c.codec, q
+		}
+	}
+	return best, best != nil
+}
+
+// quality returns the weight accept gives to mediaTypes, taken from the most
+// specific matching range as RFC 9110 section 12.5.1 requires.
+func quality(accept string, mediaTypes []string) float64 {
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		s := -1
+		for _, t := range mediaTypes {
+			switch {
+			case mediaType == t:
+				s = max(s, 2)
+			case mediaType == "*/*":
+				s = max(s, 0)
+			case strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(t, strings.TrimSuffix(mediaType, "*")):
+				s = max(s, 1)
+			}
+		}
+		if s < 0 || s < specificity {
+			continue
+		}
+		weight := 1.0
+		if raw, ok := params["q"]; ok {
+			if weight, err = strconv.ParseFloat(raw, 64); err != nil || weight < 0 || weight > 1 {
+				continue
+			}
+		}
+		if s > specificity {
+			q, specificity = weight, s
+		} else {
+			q = max(q, weight)
+		}
+	}
+	return q
+}
+
+// codecWriter carries the negotiated codec down to the response helpers.
+type codecWriter struct {
+	http.ResponseWriter
+	codec codec
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *codecWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// responseCodec finds the codec Negotiate chose for w, looking through
+// wrapping writers, and falls back to JSON.
+func responseCodec(w http.ResponseWriter) codec {
+	for {
+		switch cw := w.(type) {
+		case *codecWriter:
+			return cw.codec
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = cw.Unwrap()
+		default:
+			return jsonCodec{}
+		}
+	}
+}
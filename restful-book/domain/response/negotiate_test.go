@@ -0,0 +1,167 @@
+package response
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct{ accept, want string }{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/xml", "application/xml"},
+		{"text/xml", "application/xml"},
+		{"application/x-msgpack", "application/msgpack"},
+		{"text/html, application/xml;q=0.1", "application/xml"},
+		{"text/*", "application/xml"},
+		{"application/*", "application/json"},
+		{"application/json;q=0.5, */*;q=0.6", "application/xml"},
+		{"application/json;q=0, */*", "application/xml"},
+		{"application/*;q=0.5, application/msgpack", "application/msgpack"},
+		// Ties go to the codec listed first.
+		{"application/msgpack;q=0.5, application/xml;q=0.5", "application/xml"},
+		{"application/xml, application/json", "application/json"},
+		// The most specific range decides, whatever its weight.
+		{"application/json;q=0, application/*;q=0.9", "application/xml"},
+		{"application/xml;q=0.2, text/*;q=0.9", "application/xml"},
+		// Unparseable ranges and weights are ignored.
+		{"application/json;q=abc, application/xml;q=0.3", "application/xml"},
+		{"application/json;q=2, application/xml;q=0.3", "application/xml"},
+		{"bogus, application/xml", "application/xml"},
+		{"text/html", ""},
+		{"*/*;q=0", ""},
+		{"application/json;q=0, application/xml;q=0, application/msgpack;q=0", ""},
+		{"application/json;q=abc", ""},
+	} {
+		c, ok := negotiate(tc.accept)
+		got := ""
+		if ok {
+			got = c.contentType()
+		}
+		if got != tc.want {
+			t.Errorf("negotiate(%q) = %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestNegotiateMiddleware(t *testing.T) {
+	h := Negotiate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v struct {
+			Name string `json:"name" xml:"name"`
+		}
+		if err := Decode(r, &v); err != nil {
+			Error(w, err)
+			return
+		}
+		JSON(w, v, "Success", http.StatusOK)
+	}))
+	for _, tc := range []struct {
+		accept, contentType, body string
+		code                      int
+		responseType              string
+	}{
+		{"", "", `{"name":"a"}`, http.StatusOK, "application/json"},
+		{"application/xml", "application/json", `{"name":"a"}`, http.StatusOK, "application/xml"},
+		{"application/json", "text/xml; charset=utf-8", `<v><name>a</name></v>`, http.StatusOK, "application/json"},
+		// An unacceptable response is refused before the body is read.
+		{"text/html", "text/plain", `a`, http.StatusNotAcceptable, "application/json"},
+		{"application/xml", "text/plain", `a`, http.StatusUnsupportedMediaType, "application/xml"},
+		{"", "not a media type", `a`, http.StatusUnsupportedMediaType, "application/json"},
+		{"", "application/json", `{"name":1}`, http.StatusBadRequest, "application/json"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		if tc.contentType != "" {
+			r.Header.Set("Content-Type", tc.contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != tc.code || !strings.HasPrefix(rec.Header().Get("Content-Type"), tc.responseType) {
+			t.Errorf("Accept %q, Content-Type %q: %d %s, want %d %s", tc.accept, tc.contentType,
+				rec.Code, rec.Header().Get("Content-Type"), tc.code, tc.responseType)
+		}
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: Vary = %q", tc.accept, rec.Header().Get("Vary"))
+		}
+	}
+}
+
+type decodeTarget struct {
+	XMLName xml.Name   `json:"-" xml:"item"`
+	Name    string     `json:"name" xml:"name"`
+	Tags    []string   `json:"tags" xml:"tags>tag"`
+	When    time.Time  `json:"when" xml:"when"`
+	Inner   *decodeSub `json:"inner" xml:"inner"`
+	ID      string     `json:"id" xml:"id,attr"`
+}
+
+type decodeSub struct {
+	Value int `json:"value" xml:"value"`
+}
+
+func TestDecodeIsStrict(t *testing.T) {
+	mp := func(v any) string {
+		var buf bytes.Buffer
+		if err := (msgpackCodec{}).encode(&buf, v); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	valid := mp(map[string]any{"name": "a", "inner": map[string]any{"value": 1}})
+
+	for _, tc := range []struct {
+		name, contentType, body string
+		ok                      bool
+	}{
+		{"json", "application/json", `{"name":"a","inner":{"value":1}}`, true},
+		{"json unknown field", "application/json", `{"name":"a","nmae":"b"}`, false},
+		{"json unknown nested field", "application/json", `{"inner":{"valeu":1}}`, false},
+		{"json trailing data", "application/json", `{"name":"a"} {}`, false},
+		{"json trailing bracket", "application/json", `{"name":"a"}}`, false},
+
+		{"xml", "application/xml", `<?xml version="1.0"?><!-- c --><item id="1"><name>a</name><tags><tag>x</tag><tag>y</tag></tags>` +
+			`<when>2024-01-02T03:04:05Z</when><inner><value>1</value></inner></item>` + "\n", true},
+		{"xml unknown element", "application/xml", `<item><nmae>a</nmae></item>`, false},
+		{"xml unknown nested element", "application/xml", `<item><inner><valeu>1</valeu></inner></item>`, false},
+		{"xml two roots", "application/xml", `<item></item><item></item>`, false},
+		{"xml trailing text", "application/xml", `<item></item>x`, false},
+		{"xml unclosed", "application/xml", `<item><name>a</name>`, false},
+		{"xml empty", "application/xml", ``, false},
+
+		{"msgpack", "application/msgpack", valid, true},
+		{"msgpack unknown field", "application/msgpack", mp(map[string]any{"nmae": "a"}), false},
+		{"msgpack trailing data", "application/msgpack", valid + valid, false},
+		{"msgpack truncated", "application/msgpack", valid[:len(valid)-1], false},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+		r.Header.Set("Content-Type", tc.contentType)
+		var v decodeTarget
+		err := Decode(r, &v)
+		if tc.ok {
+			if err != nil || v.Name != "a" || v.Inner == nil || v.Inner.Value != 1 {
+				t.Errorf("%s: Decode = %+v, %v", tc.name, v, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidBody) {
+			t.Errorf("%s: Decode = %v, want ErrInvalidBody", tc.name, err)
+		}
+	}
+}
+
+func TestDecodeBodyTooLarge(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"`+strings.Repeat("a", 100)+`"}`))
+	r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, 10)
+	var v decodeTarget
+	if err := Decode(r, &v); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Decode = %v, want ErrBodyTooLarge", err)
+	}
+}
@@ -1,33 +1,74 @@
 package response
 
 import (
-	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"log"
 	"net/http"
 )
 
 type StandardResponse struct {
-	Data    any    `json:"data,omitempty"`
-	Message string `json:"message"`
-	Error   string `json:"error,omitempty"`
+	XMLName xml.Name     `json:"-" xml:"response"`
+	Data    any          `json:"data,omitempty" xml:"data,omitempty"`
+	Meta    *Meta        `json:"meta,omitempty" xml:"meta,omitempty"`
+	Message string       `json:"message" xml:"message"`
+	Code    string       `json:"code,omitempty" xml:"code,omitempty"`
+	Error   string       `json:"error,omitempty" xml:"error,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty" xml:"errors,omitempty"`
 }
 
+// Meta describes the page of a paginated collection carried in Data.
+type Meta struct {
+	Total  int `json:"total" xml:"total"`
+	Limit  int `json:"limit" xml:"limit"`
+	Offset int `json:"offset" xml:"offset"`
+}
+
+// JSON writes data in the StandardResponse envelope. Despite the name it uses
+// whichever format Negotiate chose for the request, JSON by default.
 func JSON(w http.ResponseWriter, data any, message string, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	resp := StandardResponse{
+	write(w, statusCode, StandardResponse{
 		Data:    data,
 		Message: message,
-	}
-	json.NewEncoder(w).Encode(resp)
+	})
 }
 
-func Error(w http.ResponseWriter, err error, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+func JSONPage(w http.ResponseWriter, data any, meta Meta, message string, statusCode int) {
+	write(w, statusCode, StandardResponse{
+		Data:    data,
+		Meta:    &meta,
+		Message: message,
+	})
+}
 
+// Error writes err as a JSON error envelope, deriving the status code and
+// machine-readable code from its type. Details of internal errors are logged
+// rather than sent to the client.
+func Error(w http.ResponseWriter, err error) {
+	statusCode := StatusCode(err)
 	resp := StandardResponse{
 		Message: "Failed",
+		Code:    Code(err),
 		Error:   err.Error(),
 	}
-	json.NewEncoder(w).Encode(resp)
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		resp.Error = "validation failed"
+		resp.Errors = verr.Fields
+	}
+	if statusCode == http.StatusInternalServerError {
+		log.Printf("internal error: %v", err)
+		resp.Error = "internal server error"
+	}
+	write(w, statusCode, resp)
+}
+
+// write encodes resp in the format Negotiate chose for w, JSON by default.
+func write(w http.ResponseWriter, statusCode int, resp StandardResponse) {
+	c := responseCodec(w)
+	w.Header().Set("Content-Type", c.contentType())
+	w.WriteHeader(statusCode)
+	if err := c.encode(w, resp); err != nil {
+		log.Printf("encode %s response: %v", c.contentType(), err)
+	}
 }
@@ -1,25 +1,158 @@
 package main
 
 import (
-	// "encoding/json"
+	"context"
+	"errors"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/api"
-	// "github.com/google/uuid"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/api/middleware"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/blob"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/config"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/service"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/stream"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/webhook"
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: cfg.LogLevel})))
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		log.Fatalf("failed to open storage: %v", err)
+	}
+	apiKeys, err := auth.ParseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		log.Fatalf("invalid API keys: %v", err)
+	}
+	if apiKeys.Len() == 0 {
+		slog.Warn("no API keys configured; all write requests will be rejected")
+	}
+
+	dispatcher := webhook.NewDispatcher(store.webhooks, webhook.Config{
+		Attempts:             cfg.WebhookAttempts,
+		Backoff:              cfg.WebhookBackoff,
+		Workers:              cfg.WebhookWorkers,
+		AllowPrivateNetworks: cfg.WebhookAllowPrivate,
+	})
+
+	hub := stream.NewHub(cfg.EventBuffer)
+
+	router, err := api.NewRouter(store.books, api.Options{
+		APIKeys:    apiKeys,
+		Authors:    store.authors,
+		Cache:      service.CacheConfig{TTL: cfg.CacheTTL, Size: cfg.CacheSize},
+		Covers:     store.covers,
+		Webhooks:   store.webhooks,
+		Dispatcher: dispatcher,
+		Stream:     hub,
+
+		MaxBodyBytes: int64(cfg.MaxBodyBytes),
+		RateLimit:    middleware.RateLimitConfig{PerSecond: cfg.RateLimit, Burst: cfg.RateBurst},
+	})
+	if err != nil {
+		log.Fatalf("failed to build router: %v", err)
+	}
+
 	serve := &http.Server{
-		Addr:         ":8081",
-		Handler:      api.NewRouter(),
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  30 * time.Second,
-	}
-	log.Println("Server starting on :8081")
-	if err := serve.ListenAndServe(); err != nil {
-		log.Fatalf("failed to start server: %v", err)
+		Addr:         cfg.Addr,
+		Handler:      router,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+	// Event streams never finish on their own; end them so Shutdown does
+	// not wait out its timeout on connected clients.
+	serve.RegisterOnShutdown(hub.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("server starting", "addr", cfg.Addr, "storage", cfg.Storage)
+		serveErr <- serve.ListenAndServe()
+	}()
+
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		if cfg.TrashRetention > 0 {
+			service.PurgeTrash(ctx, store.books, store.covers, cfg.TrashRetention, service.PurgeInterval, slog.Default())
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("failed to start server: %v", err)
+		}
+	case <-ctx.Done():
+		stop()
+		slog.Info("shutting down, draining in-flight requests", "timeout", cfg.ShutdownTimeout)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := serve.Shutdown(shutdownCtx); err != nil {
+			slog.Error("graceful shutdown incomplete", "err", err)
+		}
+	}
+
+	// Only close storage once no handler, purge or webhook delivery can
+	// still be using it.
+	stop()
+	<-purgeDone
+	deliveryCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := dispatcher.Close(deliveryCtx); err != nil {
+		slog.Warn("abandoned pending webhook deliveries as dead letters", "err", err)
+	}
+	if closer, ok := store.books.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			slog.Error("failed to close repository", "err", err)
+		}
+	}
+	slog.Info("server stopped")
+}
+
+// storage groups the repositories of one backend.
+type storage struct {
+	books    repository.BookRepository
+	authors  repository.AuthorRepository
+	covers   blob.Store
+	webhooks repository.WebhookRepository
+}
+
+func openStorage(cfg config.Config) (storage, error) {
+	switch cfg.Storage {
+	case "sqlite":
+		repo, err := repository.NewSQLiteBookRepository(cfg.DBPath)
+		if err != nil {
+			return storage{}, err
+		}
+		covers, err := blob.NewFileStore(cfg.CoverDir)
+		if err != nil {
+			repo.Close()
+			return storage{}, err
+		}
+		return storage{books: repo, authors: repo.Authors(), covers: covers, webhooks: repo.Webhooks()}, nil
+	default:
+		return storage{
+			books:    repository.NewBookRepository(),
+			authors:  repository.NewAuthorRepository(),
+			covers:   blob.NewMemoryStore(),
+			webhooks: repository.NewWebhookRepository(),
+		}, nil
 	}
 }
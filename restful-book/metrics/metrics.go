@@ -0,0 +1,306 @@
+// Package metrics keeps counters, gauges and histograms in memory and serves
+// them in the Prometheus text exposition format. It covers what the books
+// API reports and nothing more.
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency histogram bounds in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ContentType is the media type of the exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// collector writes the samples of one metric family.
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds the metrics exposed by one server.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo writes every metric in the order it was registered.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler serves the registry for scraping.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// family is the name, help text and label names shared by a metric's series.
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (f family) writeHeader(w *bufio.Writer) {
+	w.WriteString("# HELP " + f.name + " " + escape(f.help, false) + "\n")
+	w.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+}
+
+// series is the state of one label combination of a vector.
+type series[T any] struct {
+	values []string
+	state  T
+}
+
+// vec maps label values to series. Series are created on first use and
+// written sorted by their label values.
+type vec[T any] struct {
+	family
+	mu     sync.Mutex
+	series map[string]*series[T]
+	init   func() T
+}
+
+func newVec[T any](f family, init func() T) *vec[T] {
+	return &vec[T]{family: f, series: map[string]*series[T]{}, init: init}
+}
+
+// with calls fn on the series for values while holding the vector's lock.
+func (v *vec[T]) with(values []string, fn func(state *T)) {
+	if len(values) != len(v.labels) {
+		panic("metrics: " + v.name + " wants " + strconv.Itoa(len(v.labels)) + " label values")
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series[T]{values: slices.Clone(values), state: v.init()}
+		v.series[key] = s
+	}
+	fn(&s.state)
+}
+
+// each calls fn on every series in label order while holding the lock.
+func (v *vec[T]) each(fn func(labels string, state *T)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		s := v.series[key]
+		fn(labelPairs(v.labels, s.values), &s.state)
+	}
+}
+
+// CounterVec counts events per label combination.
+type CounterVec struct {
+	*vec[float64]
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(family{name, help, "counter", labels}, func() float64 { return 0 })}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series with the given label values.
+func (c *CounterVec) Inc(values ...string) {
+	c.with(values, func(n *float64) { *n++ })
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.each(func(labels string, n *float64) {
+		writeSample(w, c.name, labels, *n)
+	})
+}
+
+// HistogramVec observes value distributions per label combination.
+type HistogramVec struct {
+	*vec[histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates a histogram with the given upper bucket bounds,
+// which must be sorted; the +Inf bucket is implied.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{buckets: slices.Clone(buckets)}
+	h.vec = newVec(family{name, help, "histogram", labels}, func() histogram {
+		return histogram{counts: make([]uint64, len(h.buckets))}
+	})
+	r.register(h)
+	return h
+}
+
+// Observe records v in the series with the given label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	i, _ := slices.BinarySearch(h.buckets, v)
+	h.with(values, func(s *histogram) {
+		if i < len(s.counts) {
+			s.counts[i]++
+		}
+		s.count++
+		s.sum += v
+	})
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	h.each(func(labels string, s *histogram) {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", joinLabels(labels, `le="`+formatFloat(bound)+`"`), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", joinLabels(labels, `le="+Inf"`), float64(s.count))
+		writeSample(w, h.name+"_sum", labels, s.sum)
+		writeSample(w, h.name+"_count", labels, float64(s.count))
+	})
+}
+
+// Gauge is a single value that can go up and down.
+type Gauge struct {
+	family
+	mu    sync.Mutex
+	value float64
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{family: family{name: name, help: help, kind: "gauge"}}
+	r.register(g)
+	return g
+}
+
+// Add changes the gauge by delta, which may be negative.
+func (g *Gauge) Add(delta float64) {
+	g.mu.Lock()
+	g.value += delta
+	g.mu.Unlock()
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	value := g.value
+	g.mu.Unlock()
+	g.writeHeader(w)
+	writeSample(w, g.name, "", value)
+}
+
+// funcMetric reads its value when scraped.
+type funcMetric struct {
+	family
+	value func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn at scrape time.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{family{name: name, help: help, kind: "gauge"}, fn})
+}
+
+// NewCounterFunc registers a counter kept elsewhere, read from fn at scrape
+// time. fn must never decrease.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{family{name: name, help: help, kind: "counter"}, fn})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	f.writeHeader(w)
+	writeSample(w, f.name, "", f.value())
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func labelPairs(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escape(values[i], true) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escape applies the exposition format's escaping: backslash and newline
+// everywhere, double quotes in label values only.
+func escape(s string, quote bool) string {
+	r := strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	if quote {
+		r = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	}
+	return r.Replace(s)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
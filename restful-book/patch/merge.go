@@ -0,0 +1,61 @@
+// Package patch applies JSON Merge Patch documents (RFC 7396).
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+const MergePatchContentType = "application/merge-patch+json"
+
+var ErrInvalidPatch = errors.New("patch is not valid JSON")
+
+// MergePatch applies patch to the JSON document doc as described in RFC 7396:
+// objects are merged recursively, null removes a member, and any other value
+// replaces the target outright.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var p any
+	if err := decode(patch, &p); err != nil {
+		return nil, ErrInvalidPatch
+	}
+	var target any
+	if err := decode(doc, &target); err != nil {
+		return nil, err
+	}
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = merge(t[k], v)
+	}
+	return t
+}
+
+// decode keeps numbers as json.Number so large integers survive the round
+// trip unchanged.
+func decode(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return ErrInvalidPatch
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// RunAuthorConformanceTests is the RunConformanceTests counterpart for
+// AuthorRepository implementations. newRepo must return a fresh, empty
+// repository on every call.
+func RunAuthorConformanceTests(t *testing.T, newRepo func(t *testing.T) AuthorRepository) {
+	t.Helper()
+	runConformance(t, newRepo, []conformanceTest[AuthorRepository]{
+		{"NotFound", testAuthorsNotFound},
+		{"GetAllInCreationOrder", testAuthorsGetAllInCreationOrder},
+		{"UniqueNames", testAuthorsUniqueNames},
+		{"UpdateFuncIsAtomic", testAuthorsUpdateFuncIsAtomic},
+		{"DeleteFreesName", testAuthorsDeleteFreesName},
+		{"DeleteFuncCanVeto", testAuthorsDeleteFuncCanVeto},
+		{"ReferenceNeedsEveryAuthor", testAuthorsReferenceNeedsEveryAuthor},
+		{"ReferenceHoldsOffDelete", testAuthorsReferenceHoldsOffDelete},
+	})
+}
+
+func conformanceAuthor(n int) models.Author {
+	return models.Author{
+		ID:      fmt.Sprintf("author-%d", n),
+		Name:    fmt.Sprintf("Author %d", n),
+		Bio:     fmt.Sprintf("Bio %d", n),
+		OwnerID: fmt.Sprintf("owner-%d", n%3),
+	}
+}
+
+func mustCreateAuthor(t *testing.T, repo AuthorRepository, author models.Author) {
+	t.Helper()
+	if err := repo.Create(&author); err != nil {
+		t.Fatalf("Create(%q): %v", author.ID, err)
+	}
+}
+
+func testAuthorsNotFound(t *testing.T, repo AuthorRepository) {
+	authors, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectEqual(t, "GetAll", authors, []*models.Author{})
+	_, err = repo.GetByID("missing")
+	expectErr(t, "GetByID", err, response.ErrAuthorNotFound)
+	_, err = repo.UpdateFunc("missing", func(*models.Author) error { return nil })
+	expectErr(t, "UpdateFunc", err, response.ErrAuthorNotFound)
+	expectErr(t, "Delete", repo.Delete("missing"), response.ErrAuthorNotFound)
+}
+
+func testAuthorsGetAllInCreationOrder(t *testing.T, repo AuthorRepository) {
+	var want []models.Author
+	for n := 3; n > 0; n-- {
+		author := conformanceAuthor(n)
+		mustCreateAuthor(t, repo, author)
+		want = append(want, author)
+	}
+
+	got, err := repo.GetByID(want[1].ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	expectEqual(t, "GetByID", *got, want[1])
+	got.Name = "mutated after GetByID"
+
+	authors, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectAll(t, "GetAll", authors, want...)
+}
+
+func testAuthorsUniqueNames(t *testing.T, repo AuthorRepository) {
+	first, second := conformanceAuthor(1), conformanceAuthor(2)
+	mustCreateAuthor(t, repo, first)
+	mustCreateAuthor(t, repo, second)
+
+	dup := conformanceAuthor(3)
+	dup.Name = first.Name
+	expectErr(t, "Create(duplicate name)", repo.Create(&dup), response.ErrAuthorExists)
+
+	_, err := repo.UpdateFunc(second.ID, func(a *models.Author) error {
+		a.Name = first.Name
+		return nil
+	})
+	expectErr(t, "UpdateFunc(rename to taken name)", err, response.ErrAuthorExists)
+
+	// Keeping one's own name is not a conflict.
+	if _, err := repo.UpdateFunc(first.ID, func(a *models.Author) error {
+		a.Bio = "new bio"
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateFunc(same name): %v", err)
+	}
+
+	got, err := repo.GetByID(second.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	expectEqual(t, "GetByID after rejected rename", *got, second)
+}
+
+func testAuthorsUpdateFuncIsAtomic(t *testing.T, repo AuthorRepository) {
+	author := conformanceAuthor(1)
+	mustCreateAuthor(t, repo, author)
+
+	got, err := repo.UpdateFunc(author.ID, func(a *models.Author) error {
+		if !reflect.DeepEqual(*a, author) {
+			t.Errorf("UpdateFunc saw %+v, want %+v", *a, author)
+		}
+		a.Name = "Renamed"
+		a.ID = "cannot change"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateFunc: %v", err)
+	}
+	want := author
+	want.Name = "Renamed"
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("UpdateFunc returned %+v, want %+v", *got, want)
+	}
+
+	abort := errors.New("abort")
+	_, err = repo.UpdateFunc(author.ID, func(a *models.Author) error {
+		a.Name = "should not be saved"
+		return abort
+	})
+	expectErr(t, "UpdateFunc(abort)", err, abort)
+
+	stored, err := repo.GetByID(author.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	expectEqual(t, "GetByID after UpdateFunc", *stored, want)
+}
+
+func testAuthorsDeleteFreesName(t *testing.T, repo AuthorRepository) {
+	first, second := conformanceAuthor(1), conformanceAuthor(2)
+	mustCreateAuthor(t, repo, first)
+	mustCreateAuthor(t, repo, second)
+
+	if err := repo.Delete(first.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, err := repo.GetByID(first.ID)
+	expectErr(t, "GetByID after Delete", err, response.ErrAuthorNotFound)
+	expectErr(t, "Delete twice", repo.Delete(first.ID), response.ErrAuthorNotFound)
+
+	authors, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectAll(t, "GetAll after Delete", authors, second)
+
+	// The name is free again.
+	again := first
+	again.ID = "author-1-again"
+	mustCreateAuthor(t, repo, again)
+}
+
+func testAuthorsDeleteFuncCanVeto(t *testing.T, repo AuthorRepository) {
+	author := conformanceAuthor(1)
+	mustCreateAuthor(t, repo, author)
+
+	errVeto := errors.New("veto")
+	err := repo.DeleteFunc(author.ID, func(got *models.Author) error {
+		if !reflect.DeepEqual(*got, author) {
+			t.Errorf("DeleteFunc passed %+v, want %+v", *got, author)
+		}
+		return errVeto
+	})
+	expectErr(t, "DeleteFunc vetoed", err, errVeto)
+	if _, err := repo.GetByID(author.ID); err != nil {
+		t.Fatalf("GetByID after a vetoed DeleteFunc: %v", err)
+	}
+
+	if err := repo.DeleteFunc(author.ID, func(*models.Author) error { return nil }); err != nil {
+		t.Fatalf("DeleteFunc: %v", err)
+	}
+	_, err = repo.GetByID(author.ID)
+	expectErr(t, "GetByID after DeleteFunc", err, response.ErrAuthorNotFound)
+	err = repo.DeleteFunc(author.ID, func(*models.Author) error {
+		t.Error("DeleteFunc called fn for a missing author")
+		return nil
+	})
+	expectErr(t, "DeleteFunc twice", err, response.ErrAuthorNotFound)
+}
+
+func testAuthorsReferenceNeedsEveryAuthor(t *testing.T, repo AuthorRepository) {
+	first := conformanceAuthor(1)
+	mustCreateAuthor(t, repo, first)
+
+	called := false
+	err := repo.Reference([]string{first.ID, "missing"}, func() error {
+		called = true
+		return nil
+	})
+	expectErr(t, "Reference to a missing author", err, response.ErrUnknownAuthor)
+	if called {
+		t.Fatal("Reference ran fn although an author is missing")
+	}
+	errWrite := errors.New("write failed")
+	expectErr(t, "Reference", repo.Reference([]string{first.ID}, func() error { return errWrite }), errWrite)
+}
+
+func testAuthorsReferenceHoldsOffDelete(t *testing.T, repo AuthorRepository) {
+	first, second := conformanceAuthor(1), conformanceAuthor(2)
+	mustCreateAuthor(t, repo, first)
+	mustCreateAuthor(t, repo, second)
+
+	// A delete started while a write references the author waits for it.
+	release, deleted := make(chan struct{}), make(chan error, 1)
+	referencing := make(chan error, 1)
+	go func() {
+		referencing <- repo.Reference([]string{first.ID, second.ID}, func() error {
+			go func() {
+				deleted <- repo.DeleteFunc(first.ID, func(*models.Author) error { return nil })
+			}()
+			<-release
+			return nil
+		})
+	}()
+	select {
+	case err := <-deleted:
+		t.Fatalf("DeleteFunc returned %v while a Reference was running", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-referencing; err != nil {
+		t.Fatalf("Reference: %v", err)
+	}
+	if err := <-deleted; err != nil {
+		t.Fatalf("DeleteFunc after Reference: %v", err)
+	}
+}
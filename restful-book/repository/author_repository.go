@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// AuthorRepository stores authors. No two authors may share a name.
+type AuthorRepository interface {
+	// GetAll returns every author in creation order; an empty repository
+	// yields an empty slice.
+	GetAll() ([]*models.Author, error)
+	GetByID(id string) (*models.Author, error)
+	// Create returns ErrAuthorExists if the name is taken.
+	Create(author *models.Author) error
+	// UpdateFunc applies fn to the stored author and persists the result as
+	// a single atomic step, like BookRepository.UpdateFunc. Renaming to a
+	// taken name returns ErrAuthorExists.
+	UpdateFunc(id string, fn func(author *models.Author) error) (*models.Author, error)
+	// Delete removes the author whether or not books still credit it.
+	Delete(id string) error
+	// DeleteFunc removes the author if fn, called with it, returns nil.
+	// Reference waits for it, so fn can check that no book credits the
+	// author without a book write adding a credit in the meantime.
+	DeleteFunc(id string, fn func(author *models.Author) error) error
+	// Reference runs fn, typically a book write crediting the authors in
+	// ids, once they all exist, and keeps DeleteFunc from removing them
+	// until fn returns. A missing author yields ErrUnknownAuthor naming its
+	// ID. fn must not use the repository.
+	Reference(ids []string, fn func() error) error
+}
+
+func NewAuthorRepository() AuthorRepository {
+	return &AuthorRepo{}
+}
+
+type AuthorRepo struct {
+	authors []models.Author
+	mu      sync.RWMutex
+}
+
+// Create implements AuthorRepository.
+func (a *AuthorRepo) Create(author *models.Author) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.nameTaken(*author) {
+		return response.ErrAuthorExists
+	}
+	a.authors = append(a.authors, *author)
+	return nil
+}
+
+// Delete implements AuthorRepository.
+func (a *AuthorRepo) Delete(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, author := range a.authors {
+		if author.ID == id {
+			a.authors = append(a.authors[:i], a.authors[i+1:]...)
+			return nil
+		}
+	}
+	return response.ErrAuthorNotFound
+}
+
+// DeleteFunc implements AuthorRepository.
+func (a *AuthorRepo) DeleteFunc(id string, fn func(author *models.Author) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, stored := range a.authors {
+		if stored.ID == id {
+			author := stored
+			if err := fn(&author); err != nil {
+				return err
+			}
+			a.authors = append(a.authors[:i], a.authors[i+1:]...)
+			return nil
+		}
+	}
+	return response.ErrAuthorNotFound
+}
+
+// Reference implements AuthorRepository.
+func (a *AuthorRepo) Reference(ids []string, fn func() error) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, id := range ids {
+		if !slices.ContainsFunc(a.authors, func(author models.Author) bool { return author.ID == id }) {
+			return fmt.Errorf("%w: %s", response.ErrUnknownAuthor, id)
+		}
+	}
+	return fn()
+}
+
+// GetAll implements AuthorRepository.
+func (a *AuthorRepo) GetAll() ([]*models.Author, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	authors := make([]*models.Author, 0, len(a.authors))
+	for _, author := range a.authors {
+		data := author
+		authors = append(authors, &data)
+	}
+	return authors, nil
+}
+
+// GetByID implements AuthorRepository.
+func (a *AuthorRepo) GetByID(id string) (*models.Author, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, author := range a.authors {
+		if author.ID == id {
+			data := author
+			return &data, nil
+		}
+	}
+	return nil, response.ErrAuthorNotFound
+}
+
+// UpdateFunc implements AuthorRepository.
+func (a *AuthorRepo) UpdateFunc(id string, fn func(author *models.Author) error) (*models.Author, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i, stored := range a.authors {
+		if stored.ID == id {
+			author := stored
+			if err := fn(&author); err != nil {
+				return nil, err
+			}
+			author.ID = id
+			if a.nameTaken(author) {
+				return nil, response.ErrAuthorExists
+			}
+			a.authors[i] = author
+			updated := author
+			return &updated, nil
+		}
+	}
+	return nil, response.ErrAuthorNotFound
+}
+
+// nameTaken reports whether an author other than author has its name.
+func (a *AuthorRepo) nameTaken(author models.Author) bool {
+	for _, s := range a.authors {
+		if s.ID != author.ID && s.Name == author.Name {
+			return true
+		}
+	}
+	return false
+}
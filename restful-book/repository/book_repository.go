@@ -1,8 +1,10 @@
 package repository
 
 import (
-	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
@@ -12,48 +14,250 @@ type BookRepository interface {
 	GetAll() ([]*models.Book, error)
 	GetByID(id string) (*models.Book, error)
 	Create(book *models.Book) error
+	// Update replaces the stored book and increments its Version.
 	Update(id string, book models.Book) error
-	Delete(id string) error
+	// UpdateFunc applies fn to the stored book and persists the result as a
+	// single atomic step. If fn returns an error nothing is written and that
+	// error is returned.
+	// The Version is incremented after fn returns.
+	UpdateFunc(id string, fn func(book *models.Book) error) (*models.Book, error)
+	// Delete moves the book to the trash by setting its DeletedAt and
+	// incrementing its Version, and returns the trashed book. A non-zero
+	// version must match the stored Version or ErrVersionMismatch is
+	// returned. Every other method except Trash, Restore and Purge treats
+	// trashed books as absent.
+	Delete(id string, version int) (*models.Book, error)
+	// Trash returns the trashed books in creation order.
+	Trash() ([]*models.Book, error)
+	// Restore takes a book out of the trash and increments its Version. It
+	// returns ErrBookAlreadyExist if an active book is now the same work, as
+	// models.Book.SameWork defines it.
+	Restore(id string) (*models.Book, error)
+	// Purge permanently removes the books trashed before the given time and
+	// returns them, so their covers can be removed too.
+	Purge(before time.Time) ([]*models.Book, error)
 	SearchByAuthor(author string) ([]*models.Book, error)
 	SearchByTitle(title string) ([]*models.Book, error)
+	// Query returns the page of books selected by q along with the total
+	// number of matches before Limit and Offset are applied.
+	Query(q models.BookQuery) ([]*models.Book, int, error)
+	// ListAfter returns up to limit active books whose IDs sort after
+	// after, in ID order; a limit of zero means no limit. Walking the books
+	// this way never skips or repeats one, however the others change in
+	// between.
+	ListAfter(after string, limit int) ([]*models.Book, error)
+	// History returns the change log that the writes of Logged append to.
+	History() HistoryRepository
+	// Logged returns the repository with every write except Purge also
+	// appending the revision record builds to History, in the same
+	// transaction or under the same lock as the change. If the revision
+	// cannot be appended the write fails and nothing is changed.
+	Logged(record Recorder) BookRepository
 }
 
+// Recorder builds the history entry of a change from the book as it was and
+// as it is about to be saved; before is the zero Book for Create.
+type Recorder func(before, after models.Book) models.Revision
+
 func NewBookRepository() BookRepository {
 	return &BookRepo{
-		books: []models.Book{},
+		books:   []models.Book{},
+		history: &HistoryRepo{revisions: map[string][]models.Revision{}},
 	}
 }
 
 type BookRepo struct {
 	books []models.Book
-	mu    sync.RWMutex
+	// history is only appended to while mu is held.
+	history *HistoryRepo
+	mu      sync.RWMutex
+}
+
+// loggedBookRepo is the BookRepo returned by Logged.
+type loggedBookRepo struct {
+	*BookRepo
+	record Recorder
+}
+
+// History implements BookRepository.
+func (b *BookRepo) History() HistoryRepository {
+	return b.history
+}
+
+// Logged implements BookRepository.
+func (b *BookRepo) Logged(record Recorder) BookRepository {
+	return &loggedBookRepo{BookRepo: b, record: record}
+}
+
+// log appends the revision record builds for a change, if record is set.
+// Callers hold mu and save the change only if it succeeds.
+func (b *BookRepo) log(record Recorder, before, after models.Book) error {
+	if record == nil {
+		return nil
+	}
+	return b.history.Append(record(before, after))
 }
 
 // Create implements BookRepository.
 func (b *BookRepo) Create(book *models.Book) error {
+	return b.create(book, nil)
+}
+
+// Create implements BookRepository.
+func (l *loggedBookRepo) Create(book *models.Book) error {
+	return l.create(book, l.record)
+}
+
+func (b *BookRepo) create(book *models.Book, record Recorder) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	if b.duplicate(*book) {
+		return response.ErrBookAlreadyExist
+	}
+
+	created := cloneBook(*book)
+	created.Version = 1
+	created.DeletedAt = nil
+	if err := b.log(record, models.Book{}, created); err != nil {
+		return err
+	}
+	book.Version = 1
+	book.DeletedAt = nil
+	b.books = append(b.books, created)
+	return nil
+}
+
+// cloneBook copies book along with the memory its fields point to, so the
+// repository never shares state with its callers.
+func cloneBook(book models.Book) models.Book {
+	book.AuthorIDs = slices.Clone(book.AuthorIDs)
+	if book.DeletedAt != nil {
+		deletedAt := *book.DeletedAt
+		book.DeletedAt = &deletedAt
+	}
+	if book.Cover != nil {
+		cover := *book.Cover
+		book.Cover = &cover
+	}
+	return book
+}
+
+// duplicate reports whether an active book other than book is the same work.
+func (b *BookRepo) duplicate(book models.Book) bool {
 	for _, s := range b.books {
-		if s.Title == book.Title && s.Author == book.Author {
-			return response.ErrBookAlreadyExist
+		if s.DeletedAt == nil && s.ID != book.ID && s.SameWork(book) {
+			return true
 		}
 	}
+	return false
+}
 
-	b.books = append(b.books, *book)
-	return nil
+// active returns the active books, sharing the repository's memory; callers
+// must copy before handing them out.
+func (b *BookRepo) active() []models.Book {
+	books := make([]models.Book, 0, len(b.books))
+	for _, book := range b.books {
+		if book.DeletedAt == nil {
+			books = append(books, book)
+		}
+	}
+	return books
 }
 
 // Delete implements BookRepository.
-func (b *BookRepo) Delete(id string) error {
+func (b *BookRepo) Delete(id string, version int) (*models.Book, error) {
+	return b.delete(id, version, nil)
+}
+
+// Delete implements BookRepository.
+func (l *loggedBookRepo) Delete(id string, version int) (*models.Book, error) {
+	return l.delete(id, version, l.record)
+}
+
+func (b *BookRepo) delete(id string, version int, record Recorder) (*models.Book, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, books := range b.books {
+		if books.ID == id && books.DeletedAt == nil {
+			if version != 0 && books.Version != version {
+				return nil, response.ErrVersionMismatch
+			}
+			now := time.Now().UTC().Round(0)
+			deleted := cloneBook(books)
+			deleted.DeletedAt = &now
+			deleted.Version++
+			if err := b.log(record, books, deleted); err != nil {
+				return nil, err
+			}
+			b.books[i] = cloneBook(deleted)
+			return &deleted, nil
+		}
+	}
+	return nil, response.ErrBookNotFound
+}
+
+// Trash implements BookRepository.
+func (b *BookRepo) Trash() ([]*models.Book, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	booksData := []*models.Book{}
+	for _, books := range b.books {
+		if books.DeletedAt != nil {
+			data := cloneBook(books)
+			booksData = append(booksData, &data)
+		}
+	}
+	return booksData, nil
+}
+
+// Restore implements BookRepository.
+func (b *BookRepo) Restore(id string) (*models.Book, error) {
+	return b.restore(id, nil)
+}
+
+// Restore implements BookRepository.
+func (l *loggedBookRepo) Restore(id string) (*models.Book, error) {
+	return l.restore(id, l.record)
+}
+
+func (b *BookRepo) restore(id string, record Recorder) (*models.Book, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+
 	for i, books := range b.books {
-		if books.ID == id {
-			b.books = append(b.books[:i], b.books[i+1:]...)
-			return nil
+		if books.ID == id && books.DeletedAt != nil {
+			if b.duplicate(books) {
+				return nil, response.ErrBookAlreadyExist
+			}
+			restored := cloneBook(books)
+			restored.DeletedAt = nil
+			restored.Version++
+			if err := b.log(record, books, restored); err != nil {
+				return nil, err
+			}
+			b.books[i] = cloneBook(restored)
+			return &restored, nil
 		}
 	}
-	return response.ErrBookNotFound
+	return nil, response.ErrBookNotFound
+}
+
+// Purge implements BookRepository.
+func (b *BookRepo) Purge(before time.Time) ([]*models.Book, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	purged := []*models.Book{}
+	b.books = slices.DeleteFunc(b.books, func(book models.Book) bool {
+		if book.DeletedAt == nil || !book.DeletedAt.Before(before) {
+			return false
+		}
+		data := cloneBook(book)
+		purged = append(purged, &data)
+		return true
+	})
+	return purged, nil
 }
 
 // GetAll implements BookRepository.
@@ -61,12 +265,13 @@ func (b *BookRepo) GetAll() ([]*models.Book, error) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	if len(b.books) == 0 {
+	books := b.active()
+	if len(books) == 0 {
 		return nil, response.ErrNoBooks
 	}
-	booksData := make([]*models.Book, 0, len(b.books))
-	for _, b := range b.books {
-		book := b
+	booksData := make([]*models.Book, 0, len(books))
+	for _, b := range books {
+		book := cloneBook(b)
 		booksData = append(booksData, &book)
 	}
 	return booksData, nil
@@ -77,26 +282,84 @@ func (b *BookRepo) GetByID(id string) (*models.Book, error) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	for i := range b.books {
-		if b.books[i].ID == id {
-			return &b.books[i], nil
+	for _, books := range b.books {
+		if books.ID == id && books.DeletedAt == nil {
+			book := cloneBook(books)
+			return &book, nil
 		}
 	}
 	return nil, response.ErrBookNotFound
 }
 
+// Query implements BookRepository.
+func (b *BookRepo) Query(q models.BookQuery) ([]*models.Book, int, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	active := b.active()
+	if len(active) == 0 {
+		return nil, 0, response.ErrNoBooks
+	}
+
+	matched := make([]models.Book, 0, len(active))
+	for _, books := range active {
+		if q.Matches(books) {
+			matched = append(matched, books)
+		}
+	}
+	slices.SortStableFunc(matched, q.Compare)
+
+	total := len(matched)
+	start := min(q.Offset, total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+
+	booksData := make([]*models.Book, 0, end-start)
+	for _, books := range matched[start:end] {
+		data := cloneBook(books)
+		booksData = append(booksData, &data)
+	}
+	return booksData, total, nil
+}
+
+// ListAfter implements BookRepository.
+func (b *BookRepo) ListAfter(after string, limit int) ([]*models.Book, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	matched := []models.Book{}
+	for _, books := range b.books {
+		if books.DeletedAt == nil && books.ID > after {
+			matched = append(matched, books)
+		}
+	}
+	slices.SortFunc(matched, func(x, y models.Book) int { return strings.Compare(x.ID, y.ID) })
+	if limit > 0 {
+		matched = matched[:min(limit, len(matched))]
+	}
+
+	booksData := make([]*models.Book, 0, len(matched))
+	for _, books := range matched {
+		data := cloneBook(books)
+		booksData = append(booksData, &data)
+	}
+	return booksData, nil
+}
+
 // SearchByAuthor implements BookRepository.
 func (b *BookRepo) SearchByAuthor(author string) ([]*models.Book, error) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	if len(b.books) == 0 {
+	active := b.active()
+	if len(active) == 0 {
 		return nil, response.ErrNoBooks
 	}
-	booksData := make([]*models.Book, 0, len(b.books))
+	booksData := make([]*models.Book, 0, len(active))
 
-	for i, books := range b.books {
-		if b.books[i].Author == author {
-			data := books
+	for i, books := range active {
+		if active[i].Author == author {
+			data := cloneBook(books)
 			booksData = append(booksData, &data)
 		}
 	}
@@ -108,14 +371,15 @@ func (b *BookRepo) SearchByAuthor(author string) ([]*models.Book, error) {
 func (b *BookRepo) SearchByTitle(title string) ([]*models.Book, error) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	if len(b.books) == 0 {
+	active := b.active()
+	if len(active) == 0 {
 		return nil, response.ErrNoBooks
 	}
-	booksData := make([]*models.Book, 0, len(b.books))
+	booksData := make([]*models.Book, 0, len(active))
 
-	for i, books := range b.books {
-		if b.books[i].Title == title {
-			data := books
+	for i, books := range active {
+		if active[i].Title == title {
+			data := cloneBook(books)
 			booksData = append(booksData, &data)
 		}
 	}
@@ -125,16 +389,53 @@ func (b *BookRepo) SearchByTitle(title string) ([]*models.Book, error) {
 
 // Update implements BookRepository.
 func (b *BookRepo) Update(id string, book models.Book) error {
+	_, err := b.updateFunc(id, replaceWith(book), nil)
+	return err
+}
+
+// Update implements BookRepository.
+func (l *loggedBookRepo) Update(id string, book models.Book) error {
+	_, err := l.updateFunc(id, replaceWith(book), l.record)
+	return err
+}
+
+// replaceWith is the UpdateFunc callback of Update.
+func replaceWith(book models.Book) func(*models.Book) error {
+	return func(stored *models.Book) error {
+		*stored = book
+		return nil
+	}
+}
+
+// UpdateFunc implements BookRepository.
+func (b *BookRepo) UpdateFunc(id string, fn func(book *models.Book) error) (*models.Book, error) {
+	return b.updateFunc(id, fn, nil)
+}
+
+// UpdateFunc implements BookRepository.
+func (l *loggedBookRepo) UpdateFunc(id string, fn func(book *models.Book) error) (*models.Book, error) {
+	return l.updateFunc(id, fn, l.record)
+}
+
+func (b *BookRepo) updateFunc(id string, fn func(book *models.Book) error, record Recorder) (*models.Book, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	for i, books := range b.books {
-		if books.ID == id {
-			b.books[i] = book
-
-			fmt.Println(b.books[i])
-			return nil
+		if books.ID == id && books.DeletedAt == nil {
+			book := cloneBook(books)
+			if err := fn(&book); err != nil {
+				return nil, err
+			}
+			book.Version = books.Version + 1
+			book.DeletedAt = nil
+			if err := b.log(record, books, book); err != nil {
+				return nil, err
+			}
+			b.books[i] = cloneBook(book)
+			updated := book
+			return &updated, nil
 		}
 	}
-	return response.ErrBookNotFound
+	return nil, response.ErrBookNotFound
 }
@@ -0,0 +1,822 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// RunConformanceTests checks that a BookRepository implementation behaves like
+// the in-memory BookRepo. newRepo must return a fresh, empty repository on
+// every call; it is invoked once per subtest.
+//
+// Backends should call it from their own tests, ideally under -race:
+//
+//	func TestSQLiteBookRepo(t *testing.T) {
+//		repository.RunConformanceTests(t, func(t *testing.T) repository.BookRepository {
+//			r, err := repository.NewSQLiteBookRepository(filepath.Join(t.TempDir(), "books.db"))
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			t.Cleanup(func() { r.Close() })
+//			return r
+//		})
+//	}
+func RunConformanceTests(t *testing.T, newRepo func(t *testing.T) BookRepository) {
+	t.Helper()
+	runConformance(t, newRepo, []conformanceTest[BookRepository]{
+		{"EmptyRepository", testEmptyRepository},
+		{"CreateAndGetByID", testCreateAndGetByID},
+		{"CreateDuplicate", testCreateDuplicate},
+		{"GetAllOrder", testGetAllOrder},
+		{"Update", testUpdate},
+		{"UpdateFunc", testUpdateFunc},
+		{"Delete", testDelete},
+		{"Trash", testTrash},
+		{"Restore", testRestore},
+		{"Purge", testPurge},
+		{"SearchByAuthor", testSearchByAuthor},
+		{"SearchByTitle", testSearchByTitle},
+		{"Query", testQuery},
+		{"ListAfter", testListAfter},
+		{"ReturnsCopies", testReturnsCopies},
+		{"Concurrency", testConcurrency},
+		{"Logged", testLogged},
+	})
+}
+
+// conformanceTest is one subtest of a conformance suite for repositories of
+// type R.
+type conformanceTest[R any] struct {
+	name string
+	fn   func(t *testing.T, repo R)
+}
+
+// runConformance runs every test as a subtest against its own repository
+// from newRepo.
+func runConformance[R any](t *testing.T, newRepo func(t *testing.T) R, tests []conformanceTest[R]) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func conformanceBook(n int) models.Book {
+	book := models.Book{
+		ID:            fmt.Sprintf("book-%d", n),
+		Title:         fmt.Sprintf("Title %d", n),
+		Author:        fmt.Sprintf("Author %d", n),
+		PublishedYear: 2000 + n,
+		ISBN:          fmt.Sprintf("isbn-%d", n),
+		Description:   fmt.Sprintf("Description %d", n),
+		AuthorIDs:     []string{fmt.Sprintf("author-%d", n%2)},
+		Version:       1,
+		OwnerID:       fmt.Sprintf("owner-%d", n%3),
+	}
+	if n%2 == 1 {
+		book.Cover = &models.Cover{
+			ContentType: "image/png",
+			Size:        int64(1000 + n),
+			Width:       300,
+			Height:      400,
+			SHA256:      fmt.Sprintf("sha-%d", n),
+			UpdatedAt:   time.Date(2024, 1, n, 12, 0, 0, 0, time.UTC),
+		}
+	}
+	return book
+}
+
+func mustCreate(t *testing.T, repo BookRepository, book models.Book) {
+	t.Helper()
+	if err := repo.Create(&book); err != nil {
+		t.Fatalf("Create(%q): %v", book.ID, err)
+	}
+}
+
+func expectErr(t *testing.T, op string, got, want error) {
+	t.Helper()
+	if !errors.Is(got, want) {
+		t.Fatalf("%s: got error %v, want %v", op, got, want)
+	}
+}
+
+// expectEqual fails unless got and want are deeply equal. It tells an empty
+// slice from a nil one, which several contracts promise.
+func expectEqual[T any](t *testing.T, op string, got, want T) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%s = %+v, want %+v", op, got, want)
+	}
+}
+
+// expectAll fails unless got points to exactly the values in want, in order.
+func expectAll[T any](t *testing.T, op string, got []*T, want ...T) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d results, want %d", op, len(got), len(want))
+	}
+	for i := range want {
+		if got[i] == nil || !reflect.DeepEqual(*got[i], want[i]) {
+			t.Fatalf("%s: result %d = %+v, want %+v", op, i, got[i], want[i])
+		}
+	}
+}
+
+func testEmptyRepository(t *testing.T, repo BookRepository) {
+	_, err := repo.GetAll()
+	expectErr(t, "GetAll", err, response.ErrNoBooks)
+	_, err = repo.GetByID("missing")
+	expectErr(t, "GetByID", err, response.ErrBookNotFound)
+	_, err = repo.SearchByAuthor("nobody")
+	expectErr(t, "SearchByAuthor", err, response.ErrNoBooks)
+	_, err = repo.SearchByTitle("nothing")
+	expectErr(t, "SearchByTitle", err, response.ErrNoBooks)
+	expectErr(t, "Update", repo.Update("missing", conformanceBook(1)), response.ErrBookNotFound)
+	_, err = repo.Delete("missing", 0)
+	expectErr(t, "Delete", err, response.ErrBookNotFound)
+}
+
+func testCreateAndGetByID(t *testing.T, repo BookRepository) {
+	book := conformanceBook(1)
+	mustCreate(t, repo, book)
+
+	got, err := repo.GetByID(book.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	expectEqual(t, "GetByID", *got, book)
+
+	_, err = repo.GetByID("missing")
+	expectErr(t, "GetByID(missing)", err, response.ErrBookNotFound)
+
+	// Create assigns the first version regardless of what the caller sent.
+	fresh := conformanceBook(2)
+	fresh.Version = 0
+	if err := repo.Create(&fresh); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if fresh.Version != 1 {
+		t.Fatalf("Create set Version = %d, want 1", fresh.Version)
+	}
+}
+
+func testCreateDuplicate(t *testing.T, repo BookRepository) {
+	book := conformanceBook(1)
+	mustCreate(t, repo, book)
+
+	dup := book
+	dup.ID = "another-id"
+	expectErr(t, "Create(duplicate)", repo.Create(&dup), response.ErrBookAlreadyExist)
+
+	sameTitle := book
+	sameTitle.ID = "same-title"
+	sameTitle.AuthorIDs = []string{"author-other"}
+	if err := repo.Create(&sameTitle); err != nil {
+		t.Fatalf("Create(same title, other author): %v", err)
+	}
+
+	// Linked authors are compared by ID, whatever the author text says.
+	respelled := book
+	respelled.ID = "respelled"
+	respelled.Author = "A. N. Author"
+	expectErr(t, "Create(same author IDs, other author text)", repo.Create(&respelled), response.ErrBookAlreadyExist)
+	coauthored := sameTitle
+	coauthored.ID = "coauthored"
+	coauthored.AuthorIDs = []string{"author-other", book.AuthorIDs[0]}
+	mustCreate(t, repo, coauthored)
+	reordered := coauthored
+	reordered.ID = "reordered"
+	reordered.AuthorIDs = []string{book.AuthorIDs[0], "author-other"}
+	expectErr(t, "Create(same author IDs, other order)", repo.Create(&reordered), response.ErrBookAlreadyExist)
+
+	// Without author IDs the author text decides.
+	unlinked := book
+	unlinked.ID, unlinked.AuthorIDs = "unlinked", nil
+	mustCreate(t, repo, unlinked)
+	unlinkedDup := unlinked
+	unlinkedDup.ID = "unlinked-dup"
+	expectErr(t, "Create(duplicate without author IDs)", repo.Create(&unlinkedDup), response.ErrBookAlreadyExist)
+	unlinkedOther := unlinked
+	unlinkedOther.ID, unlinkedOther.Author = "unlinked-other", "Someone Else"
+	mustCreate(t, repo, unlinkedOther)
+
+	books, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectAll(t, "GetAll", books, book, sameTitle, coauthored, unlinked, unlinkedOther)
+}
+
+func testGetAllOrder(t *testing.T, repo BookRepository) {
+	var want []models.Book
+	for n := 3; n > 0; n-- {
+		book := conformanceBook(n)
+		mustCreate(t, repo, book)
+		want = append(want, book)
+	}
+
+	books, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectAll(t, "GetAll", books, want...)
+}
+
+func testUpdate(t *testing.T, repo BookRepository) {
+	first, second := conformanceBook(1), conformanceBook(2)
+	mustCreate(t, repo, first)
+	mustCreate(t, repo, second)
+
+	updated := first
+	updated.Title = "Updated Title"
+	updated.Description = "Updated description"
+	if err := repo.Update(first.ID, updated); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	updated.Version = first.Version + 1
+
+	got, err := repo.GetByID(first.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	expectEqual(t, "GetByID after Update", *got, updated)
+
+	books, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectAll(t, "GetAll after Update", books, updated, second)
+
+	expectErr(t, "Update(missing)", repo.Update("missing", updated), response.ErrBookNotFound)
+}
+
+func testUpdateFunc(t *testing.T, repo BookRepository) {
+	book := conformanceBook(1)
+	mustCreate(t, repo, book)
+
+	got, err := repo.UpdateFunc(book.ID, func(b *models.Book) error {
+		if !reflect.DeepEqual(*b, book) {
+			t.Errorf("UpdateFunc saw %+v, want %+v", *b, book)
+		}
+		b.Description = "patched"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateFunc: %v", err)
+	}
+	want := book
+	want.Description = "patched"
+	want.Version = book.Version + 1
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("UpdateFunc returned %+v, want %+v", *got, want)
+	}
+
+	// An error from fn aborts the update.
+	abort := errors.New("abort")
+	_, err = repo.UpdateFunc(book.ID, func(b *models.Book) error {
+		b.Description = "should not be saved"
+		return abort
+	})
+	expectErr(t, "UpdateFunc(abort)", err, abort)
+
+	stored, err := repo.GetByID(book.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	expectEqual(t, "GetByID after aborted UpdateFunc", *stored, want)
+
+	_, err = repo.UpdateFunc("missing", func(*models.Book) error { return nil })
+	expectErr(t, "UpdateFunc(missing)", err, response.ErrBookNotFound)
+}
+
+func testDelete(t *testing.T, repo BookRepository) {
+	first, second := conformanceBook(1), conformanceBook(2)
+	mustCreate(t, repo, first)
+	mustCreate(t, repo, second)
+
+	_, err := repo.Delete(first.ID, first.Version+1)
+	expectErr(t, "Delete(stale version)", err, response.ErrVersionMismatch)
+	deleted, err := repo.Delete(first.ID, first.Version)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if deleted.DeletedAt == nil || deleted.Version != first.Version+1 {
+		t.Fatalf("Delete returned %+v, want a trashed book at version %d", deleted, first.Version+1)
+	}
+	trash, err := repo.Trash()
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+	expectAll(t, "Trash after Delete", trash, *deleted)
+
+	_, err = repo.GetByID(first.ID)
+	expectErr(t, "GetByID after Delete", err, response.ErrBookNotFound)
+	_, err = repo.Delete(first.ID, 0)
+	expectErr(t, "Delete twice", err, response.ErrBookNotFound)
+
+	books, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectAll(t, "GetAll after Delete", books, second)
+
+	if _, err := repo.Delete(second.ID, 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, err = repo.GetAll()
+	expectErr(t, "GetAll after deleting everything", err, response.ErrNoBooks)
+	_, _, err = repo.Query(models.BookQuery{})
+	expectErr(t, "Query after deleting everything", err, response.ErrNoBooks)
+	_, err = repo.SearchByAuthor(second.Author)
+	expectErr(t, "SearchByAuthor after deleting everything", err, response.ErrNoBooks)
+	expectErr(t, "Update(deleted)", repo.Update(first.ID, first), response.ErrBookNotFound)
+	_, err = repo.UpdateFunc(first.ID, func(*models.Book) error { return nil })
+	expectErr(t, "UpdateFunc(deleted)", err, response.ErrBookNotFound)
+
+	// A deleted book no longer blocks re-creating the same title and author.
+	again := first
+	again.ID = "book-1-again"
+	mustCreate(t, repo, again)
+}
+
+func testTrash(t *testing.T, repo BookRepository) {
+	trash, err := repo.Trash()
+	if err != nil {
+		t.Fatalf("Trash(empty): %v", err)
+	}
+	expectAll(t, "Trash(empty)", trash)
+
+	first, second, third := conformanceBook(1), conformanceBook(2), conformanceBook(3)
+	mustCreate(t, repo, first)
+	mustCreate(t, repo, second)
+	mustCreate(t, repo, third)
+
+	before := time.Now()
+	for _, book := range []models.Book{third, first} {
+		if _, err := repo.Delete(book.ID, 0); err != nil {
+			t.Fatalf("Delete(%s): %v", book.ID, err)
+		}
+	}
+	after := time.Now()
+
+	trash, err = repo.Trash()
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+	if len(trash) != 2 {
+		t.Fatalf("Trash returned %d books, want 2", len(trash))
+	}
+	for i, want := range []models.Book{first, third} {
+		got := *trash[i]
+		if got.DeletedAt == nil || got.DeletedAt.Before(before) || got.DeletedAt.After(after) {
+			t.Fatalf("Trash[%d].DeletedAt = %v, want between %v and %v", i, got.DeletedAt, before, after)
+		}
+		got.DeletedAt = nil
+		want.Version++
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Trash[%d] = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// Mutating a returned book must not reach the stored one.
+	*trash[0].DeletedAt = time.Time{}
+	trash, err = repo.Trash()
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+	if trash[0].DeletedAt.IsZero() {
+		t.Fatal("stored DeletedAt was mutated through a returned pointer")
+	}
+
+	books, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectAll(t, "GetAll", books, second)
+}
+
+func testRestore(t *testing.T, repo BookRepository) {
+	book := conformanceBook(1)
+	mustCreate(t, repo, book)
+
+	_, err := repo.Restore(book.ID)
+	expectErr(t, "Restore(active)", err, response.ErrBookNotFound)
+	_, err = repo.Restore("missing")
+	expectErr(t, "Restore(missing)", err, response.ErrBookNotFound)
+
+	if _, err := repo.Delete(book.ID, 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	restored, err := repo.Restore(book.ID)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	want := book
+	want.Version += 2
+	expectEqual(t, "Restore", *restored, want)
+	got, err := repo.GetByID(book.ID)
+	if err != nil {
+		t.Fatalf("GetByID after Restore: %v", err)
+	}
+	expectEqual(t, "GetByID after Restore", *got, want)
+	trash, err := repo.Trash()
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+	expectAll(t, "Trash after Restore", trash)
+
+	// A book recreated while the original was in the trash blocks the
+	// restore rather than leaving two active copies.
+	if _, err := repo.Delete(book.ID, 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	replacement := book
+	replacement.ID = "replacement"
+	mustCreate(t, repo, replacement)
+	_, err = repo.Restore(book.ID)
+	expectErr(t, "Restore(duplicate)", err, response.ErrBookAlreadyExist)
+}
+
+func testPurge(t *testing.T, repo BookRepository) {
+	// old has a cover, which callers need to remove once it is purged.
+	kept, old, recent := conformanceBook(2), conformanceBook(1), conformanceBook(3)
+	mustCreate(t, repo, kept)
+	mustCreate(t, repo, old)
+	mustCreate(t, repo, recent)
+
+	if _, err := repo.Delete(old.ID, 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	if _, err := repo.Delete(recent.ID, 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	purged, err := repo.Purge(cutoff)
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if len(purged) != 1 || purged[0].ID != old.ID || purged[0].DeletedAt == nil || purged[0].Cover == nil {
+		t.Fatalf("Purge = %+v, want only trashed %s with its cover", purged, old.ID)
+	}
+	trash, err := repo.Trash()
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != recent.ID {
+		t.Fatalf("Trash after Purge = %+v, want only %s", trash, recent.ID)
+	}
+	_, err = repo.Restore(old.ID)
+	expectErr(t, "Restore(purged)", err, response.ErrBookNotFound)
+
+	// Purge never touches active books, however late the cutoff.
+	if purged, err := repo.Purge(time.Now().Add(time.Hour)); err != nil || len(purged) != 1 {
+		t.Fatalf("Purge(all) = %+v, %v, want 1 book", purged, err)
+	}
+	books, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectAll(t, "GetAll after Purge", books, kept)
+}
+
+func testListAfter(t *testing.T, repo BookRepository) {
+	books, err := repo.ListAfter("", 0)
+	if err != nil {
+		t.Fatalf("ListAfter on an empty repository: %v", err)
+	}
+	expectAll(t, "ListAfter on an empty repository", books)
+
+	// Created out of ID order.
+	b3, b1, b4, b2 := conformanceBook(3), conformanceBook(1), conformanceBook(4), conformanceBook(2)
+	for _, book := range []models.Book{b3, b1, b4, b2} {
+		mustCreate(t, repo, book)
+	}
+	deleted, err := repo.Delete(b2.ID, 0)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	for _, tc := range []struct {
+		after string
+		limit int
+		want  []models.Book
+	}{
+		{"", 0, []models.Book{b1, b3, b4}},
+		{"", 2, []models.Book{b1, b3}},
+		{b1.ID, 1, []models.Book{b3}},
+		// The trashed book's ID still works as a starting point.
+		{deleted.ID, 5, []models.Book{b3, b4}},
+		{b4.ID, 0, nil},
+	} {
+		books, err := repo.ListAfter(tc.after, tc.limit)
+		if err != nil {
+			t.Fatalf("ListAfter(%q, %d): %v", tc.after, tc.limit, err)
+		}
+		expectAll(t, fmt.Sprintf("ListAfter(%q, %d)", tc.after, tc.limit), books, tc.want...)
+	}
+}
+
+func testSearchByAuthor(t *testing.T, repo BookRepository) {
+	a, b, c := conformanceBook(1), conformanceBook(2), conformanceBook(3)
+	c.Author = a.Author
+	mustCreate(t, repo, a)
+	mustCreate(t, repo, b)
+	mustCreate(t, repo, c)
+
+	books, err := repo.SearchByAuthor(a.Author)
+	if err != nil {
+		t.Fatalf("SearchByAuthor: %v", err)
+	}
+	expectAll(t, "SearchByAuthor", books, a, c)
+
+	books, err = repo.SearchByAuthor("Nobody")
+	if err != nil {
+		t.Fatalf("SearchByAuthor(no match): %v", err)
+	}
+	expectAll(t, "SearchByAuthor(no match)", books)
+
+	// Matching is exact, not substring or case-insensitive.
+	books, err = repo.SearchByAuthor("author 1")
+	if err != nil {
+		t.Fatalf("SearchByAuthor(case): %v", err)
+	}
+	expectAll(t, "SearchByAuthor(case)", books)
+}
+
+func testSearchByTitle(t *testing.T, repo BookRepository) {
+	a, b, c := conformanceBook(1), conformanceBook(2), conformanceBook(3)
+	c.Title = b.Title
+	mustCreate(t, repo, a)
+	mustCreate(t, repo, b)
+	mustCreate(t, repo, c)
+
+	books, err := repo.SearchByTitle(b.Title)
+	if err != nil {
+		t.Fatalf("SearchByTitle: %v", err)
+	}
+	expectAll(t, "SearchByTitle", books, b, c)
+
+	books, err = repo.SearchByTitle("Title")
+	if err != nil {
+		t.Fatalf("SearchByTitle(prefix): %v", err)
+	}
+	expectAll(t, "SearchByTitle(prefix)", books)
+}
+
+func testQuery(t *testing.T, repo BookRepository) {
+	_, _, err := repo.Query(models.BookQuery{})
+	expectErr(t, "Query(empty repository)", err, response.ErrNoBooks)
+
+	books := make([]models.Book, 6)
+	for i := range books {
+		books[i] = conformanceBook(i + 1)
+	}
+	books[0].Author, books[2].Author, books[4].Author = "Shared", "Shared", "Shared"
+	books[0].PublishedYear, books[2].PublishedYear, books[4].PublishedYear = 1990, 2010, 2010
+	books[0].ISBN, books[2].ISBN, books[4].ISBN = "978-1", "978-2", "979-3"
+	books[0].AuthorIDs = []string{"author-1", "author-0"}
+	for _, book := range books {
+		mustCreate(t, repo, book)
+	}
+
+	tests := []struct {
+		name  string
+		query models.BookQuery
+		total int
+		want  []models.Book
+	}{
+		{"NoFilter", models.BookQuery{}, 6, books},
+		{"Author", models.BookQuery{Author: "Shared"}, 3, []models.Book{books[0], books[2], books[4]}},
+		{"Title", models.BookQuery{Title: books[1].Title}, 1, []models.Book{books[1]}},
+		{"AuthorID", models.BookQuery{AuthorID: "author-0"}, 4, []models.Book{books[0], books[1], books[3], books[5]}},
+		{"YearRange", models.BookQuery{Author: "Shared", YearFrom: 2000, YearTo: 2010}, 2, []models.Book{books[2], books[4]}},
+		{"ISBNPrefix", models.BookQuery{ISBNPrefix: "978-"}, 2, []models.Book{books[0], books[2]}},
+		{"Combined", models.BookQuery{Author: "Shared", YearFrom: 2000, ISBNPrefix: "979"}, 1, []models.Book{books[4]}},
+		{"NoMatch", models.BookQuery{Author: "Nobody"}, 0, nil},
+		{"Limit", models.BookQuery{Limit: 2}, 6, books[:2]},
+		{"Offset", models.BookQuery{Limit: 2, Offset: 5}, 6, books[5:]},
+		{"OffsetPastEnd", models.BookQuery{Offset: 10}, 6, nil},
+		{
+			"SortDescStable",
+			models.BookQuery{Author: "Shared", Sort: []models.SortField{{Field: "published_year", Desc: true}}},
+			3, []models.Book{books[2], books[4], books[0]},
+		},
+		{
+			"SortMultiple",
+			models.BookQuery{Author: "Shared", Sort: []models.SortField{{Field: "published_year"}, {Field: "title", Desc: true}}},
+			3, []models.Book{books[0], books[4], books[2]},
+		},
+	}
+	for _, tt := range tests {
+		got, total, err := repo.Query(tt.query)
+		if err != nil {
+			t.Fatalf("Query(%s): %v", tt.name, err)
+		}
+		if total != tt.total {
+			t.Fatalf("Query(%s): total = %d, want %d", tt.name, total, tt.total)
+		}
+		expectAll(t, "Query("+tt.name+")", got, tt.want...)
+	}
+}
+
+// testReturnsCopies pins down that callers never share memory with the
+// repository: mutating a book passed to Create or returned from a read must
+// not change what is stored.
+func testReturnsCopies(t *testing.T, repo BookRepository) {
+	book := conformanceBook(1)
+	input := book
+	input.AuthorIDs = slices.Clone(book.AuthorIDs)
+	cover := *book.Cover
+	input.Cover = &cover
+	if err := repo.Create(&input); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	input.Title = "mutated after Create"
+	input.AuthorIDs[0] = "mutated after Create"
+	input.Cover.SHA256 = "mutated after Create"
+
+	got, err := repo.GetByID(book.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	got.Title = "mutated after GetByID"
+	got.AuthorIDs[0] = "mutated after GetByID"
+	got.Cover.SHA256 = "mutated after GetByID"
+
+	all, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	all[0].Title = "mutated after GetAll"
+	all[0].AuthorIDs[0] = "mutated after GetAll"
+
+	byAuthor, err := repo.SearchByAuthor(book.Author)
+	if err != nil {
+		t.Fatalf("SearchByAuthor: %v", err)
+	}
+	byAuthor[0].Title = "mutated after SearchByAuthor"
+
+	byTitle, err := repo.SearchByTitle(book.Title)
+	if err != nil {
+		t.Fatalf("SearchByTitle: %v", err)
+	}
+	byTitle[0].Author = "mutated after SearchByTitle"
+
+	got, err = repo.GetByID(book.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if !reflect.DeepEqual(*got, book) {
+		t.Fatalf("stored book was mutated through a returned pointer: %+v, want %+v", *got, book)
+	}
+}
+
+func testConcurrency(t *testing.T, repo BookRepository) {
+	const workers = 8
+	const perWorker = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker*4)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				book := conformanceBook(w*perWorker + i)
+				if err := repo.Create(&book); err != nil {
+					errs <- fmt.Errorf("Create(%s): %w", book.ID, err)
+					continue
+				}
+				if _, err := repo.GetByID(book.ID); err != nil {
+					errs <- fmt.Errorf("GetByID(%s): %w", book.ID, err)
+				}
+				book.Description = "updated"
+				if err := repo.Update(book.ID, book); err != nil {
+					errs <- fmt.Errorf("Update(%s): %w", book.ID, err)
+				}
+				_, err := repo.UpdateFunc(book.ID, func(b *models.Book) error {
+					b.PublishedYear++
+					return nil
+				})
+				if err != nil {
+					errs <- fmt.Errorf("UpdateFunc(%s): %w", book.ID, err)
+				}
+				if _, err := repo.SearchByAuthor(book.Author); err != nil {
+					errs <- fmt.Errorf("SearchByAuthor(%s): %w", book.Author, err)
+				}
+			}
+		}(w)
+	}
+
+	// Racing duplicates: exactly one of these may win.
+	dup := conformanceBook(workers * perWorker)
+	var created sync.WaitGroup
+	wins := make(chan struct{}, workers)
+	for w := 0; w < workers; w++ {
+		created.Add(1)
+		go func(w int) {
+			defer created.Done()
+			book := dup
+			book.ID = fmt.Sprintf("dup-%d", w)
+			err := repo.Create(&book)
+			switch {
+			case err == nil:
+				wins <- struct{}{}
+			case !errors.Is(err, response.ErrBookAlreadyExist):
+				errs <- fmt.Errorf("Create(duplicate): %w", err)
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	created.Wait()
+	close(errs)
+	close(wins)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := len(wins); n != 1 {
+		t.Errorf("%d concurrent duplicate creates succeeded, want 1", n)
+	}
+
+	books, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if want := workers*perWorker + 1; len(books) != want {
+		t.Fatalf("GetAll returned %d books, want %d", len(books), want)
+	}
+}
+
+func testLogged(t *testing.T, repo BookRepository) {
+	var calls []string
+	logged := repo.Logged(func(before, after models.Book) models.Revision {
+		calls = append(calls, fmt.Sprintf("%d->%d", before.Version, after.Version))
+		return models.Revision{BookID: after.ID, Revision: after.Version, Action: "test", Book: after}
+	})
+
+	book := conformanceBook(1)
+	if err := logged.Create(&book); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := logged.UpdateFunc(book.ID, func(b *models.Book) error { b.Title = "Changed"; return nil }); err != nil {
+		t.Fatalf("UpdateFunc: %v", err)
+	}
+	if _, err := logged.Delete(book.ID, 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := logged.Restore(book.ID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if err := logged.Update(book.ID, book); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// Writes that fail and writes through the repository itself log nothing.
+	_, err := logged.UpdateFunc(book.ID, func(*models.Book) error { return response.ErrForbidden })
+	expectErr(t, "UpdateFunc failing", err, response.ErrForbidden)
+	other := conformanceBook(2)
+	mustCreate(t, repo, other)
+
+	if got := strings.Join(calls, ","); got != "0->1,1->2,2->3,3->4,4->5" {
+		t.Fatalf("recorded changes %s, want 0->1,1->2,2->3,3->4,4->5", got)
+	}
+	revisions, err := repo.History().List(book.ID)
+	if err != nil {
+		t.Fatalf("History().List: %v", err)
+	}
+	if len(revisions) != 5 || revisions[4].Revision != 5 || revisions[1].Book.Title != "Changed" {
+		t.Fatalf("History().List = %+v", revisions)
+	}
+	_, err = repo.History().List(other.ID)
+	expectErr(t, "History().List(unlogged)", err, response.ErrBookNotFound)
+
+	// A revision that cannot be appended undoes its write.
+	if err := repo.History().Append(models.Revision{BookID: book.ID, Revision: 6}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if _, err := logged.UpdateFunc(book.ID, func(b *models.Book) error { b.Title = "Lost"; return nil }); err == nil {
+		t.Fatal("UpdateFunc succeeded although its revision was taken")
+	}
+	if _, err := logged.Delete(book.ID, 0); err == nil {
+		t.Fatal("Delete succeeded although its revision was taken")
+	}
+	got, err := repo.GetByID(book.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Version != 5 || got.Title != book.Title {
+		t.Fatalf("GetByID after failed writes = %+v", *got)
+	}
+}
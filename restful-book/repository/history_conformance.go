@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// RunHistoryConformanceTests is the RunConformanceTests counterpart for
+// HistoryRepository implementations. newRepo must return a fresh, empty
+// repository on every call.
+func RunHistoryConformanceTests(t *testing.T, newRepo func(t *testing.T) HistoryRepository) {
+	t.Helper()
+	runConformance(t, newRepo, []conformanceTest[HistoryRepository]{
+		{"NoHistory", testHistoryNoHistory},
+		{"ListOldestFirst", testHistoryListOldestFirst},
+		{"OneEntryPerRevision", testHistoryOneEntryPerRevision},
+		{"AppendOnly", testHistoryAppendOnly},
+	})
+}
+
+func conformanceRevision(book models.Book, revision int) models.Revision {
+	before := book
+	before.Title = fmt.Sprintf("%s (rev %d)", book.Title, revision-1)
+	after := book
+	after.Version = revision
+	deletedAt := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	after.DeletedAt = &deletedAt
+	return models.Revision{
+		BookID:    book.ID,
+		Revision:  revision,
+		Action:    models.ActionUpdated,
+		Actor:     book.OwnerID,
+		RequestID: fmt.Sprintf("req-%d", revision),
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, revision, time.UTC),
+		Changes:   models.DiffBooks(before, after),
+		Book:      after,
+	}
+}
+
+func mustAppend(t *testing.T, repo HistoryRepository, rev models.Revision) {
+	t.Helper()
+	if err := repo.Append(rev); err != nil {
+		t.Fatalf("Append(%s, %d): %v", rev.BookID, rev.Revision, err)
+	}
+}
+
+func testHistoryNoHistory(t *testing.T, repo HistoryRepository) {
+	_, err := repo.List("missing")
+	expectErr(t, "List", err, response.ErrBookNotFound)
+	_, err = repo.Get("missing", 1)
+	expectErr(t, "Get", err, response.ErrRevisionNotFound)
+}
+
+// testHistoryListOldestFirst appends the revisions of two books interleaved
+// and expects each book to list only its own, in revision order.
+func testHistoryListOldestFirst(t *testing.T, repo HistoryRepository) {
+	a, b := conformanceBook(1), conformanceBook(2)
+	want := map[string][]models.Revision{}
+	for n := 1; n <= 3; n++ {
+		for _, book := range []models.Book{a, b} {
+			rev := conformanceRevision(book, n)
+			switch n {
+			case 1:
+				rev.Action = models.ActionCreated
+				rev.Changes = models.DiffBooks(models.Book{}, rev.Book)
+			case 3:
+				rev.Action = models.ActionReverted
+				rev.RevertedFrom = 1
+			}
+			mustAppend(t, repo, rev)
+			want[book.ID] = append(want[book.ID], rev)
+		}
+	}
+
+	for _, book := range []models.Book{a, b} {
+		got, err := repo.List(book.ID)
+		if err != nil {
+			t.Fatalf("List(%s): %v", book.ID, err)
+		}
+		expectEqual(t, "List("+book.ID+")", got, want[book.ID])
+		for _, rev := range want[book.ID] {
+			got, err := repo.Get(book.ID, rev.Revision)
+			if err != nil {
+				t.Fatalf("Get(%s, %d): %v", book.ID, rev.Revision, err)
+			}
+			expectEqual(t, fmt.Sprintf("Get(%s, %d)", book.ID, rev.Revision), *got, rev)
+		}
+	}
+	for _, revision := range []int{0, 4} {
+		_, err := repo.Get(a.ID, revision)
+		expectErr(t, fmt.Sprintf("Get(%s, %d)", a.ID, revision), err, response.ErrRevisionNotFound)
+	}
+}
+
+// testHistoryOneEntryPerRevision pins down that a revision number, once
+// recorded for a book, can never be recorded again or overwritten.
+func testHistoryOneEntryPerRevision(t *testing.T, repo HistoryRepository) {
+	rev := conformanceRevision(conformanceBook(1), 1)
+	mustAppend(t, repo, rev)
+
+	dup := rev
+	dup.Action = models.ActionDeleted
+	if err := repo.Append(dup); err == nil {
+		t.Fatal("Append(duplicate revision) succeeded, want an error")
+	}
+	list, err := repo.List(rev.BookID)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	expectEqual(t, "List after duplicate Append", list, []models.Revision{rev})
+
+	// Revision numbers are per book.
+	mustAppend(t, repo, conformanceRevision(conformanceBook(2), 1))
+}
+
+// testHistoryAppendOnly pins down that the log cannot be edited through
+// values passed to Append or returned from reads.
+func testHistoryAppendOnly(t *testing.T, repo HistoryRepository) {
+	rev := conformanceRevision(conformanceBook(1), 1)
+	want := conformanceRevision(conformanceBook(1), 1)
+	mutate := func(rev *models.Revision) {
+		rev.Changes[0] = models.FieldChange{Field: "mutated", After: json.RawMessage(`1`)}
+		rev.Book.AuthorIDs[0] = "mutated"
+		*rev.Book.DeletedAt = time.Time{}
+		rev.Book.Cover.Size = -1
+	}
+	mustAppend(t, repo, rev)
+	rev.Changes[0].After[0] = 'x'
+	mutate(&rev)
+
+	list, err := repo.List(want.BookID)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	mutate(&list[0])
+	got, err := repo.Get(want.BookID, want.Revision)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	mutate(got)
+
+	got, err = repo.Get(want.BookID, want.Revision)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	expectEqual(t, "Get after mutating earlier results", *got, want)
+}
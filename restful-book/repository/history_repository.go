@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// HistoryRepository stores the append-only change log of every book. Entries
+// are never updated or removed, not even when their book is purged.
+type HistoryRepository interface {
+	// Append records rev. Each book can have only one entry per revision.
+	Append(rev models.Revision) error
+	// List returns the revisions of a book, oldest first, or
+	// ErrBookNotFound if it has none.
+	List(bookID string) ([]models.Revision, error)
+	// Get returns one revision of a book or ErrRevisionNotFound.
+	Get(bookID string, revision int) (*models.Revision, error)
+}
+
+func NewHistoryRepository() HistoryRepository {
+	return &HistoryRepo{revisions: map[string][]models.Revision{}}
+}
+
+type HistoryRepo struct {
+	revisions map[string][]models.Revision
+	mu        sync.RWMutex
+}
+
+// Append implements HistoryRepository.
+func (h *HistoryRepo) Append(rev models.Revision) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, r := range h.revisions[rev.BookID] {
+		if r.Revision == rev.Revision {
+			return fmt.Errorf("book %s already has revision %d", rev.BookID, rev.Revision)
+		}
+	}
+	h.revisions[rev.BookID] = append(h.revisions[rev.BookID], copyRevision(rev))
+	return nil
+}
+
+// List implements HistoryRepository.
+func (h *HistoryRepo) List(bookID string) ([]models.Revision, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	revisions := h.revisions[bookID]
+	if len(revisions) == 0 {
+		return nil, response.ErrBookNotFound
+	}
+	list := make([]models.Revision, 0, len(revisions))
+	for _, rev := range revisions {
+		list = append(list, copyRevision(rev))
+	}
+	return list, nil
+}
+
+// Get implements HistoryRepository.
+func (h *HistoryRepo) Get(bookID string, revision int) (*models.Revision, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, rev := range h.revisions[bookID] {
+		if rev.Revision == revision {
+			found := copyRevision(rev)
+			return &found, nil
+		}
+	}
+	return nil, response.ErrRevisionNotFound
+}
+
+// copyRevision deep-copies the parts of rev that share memory, so callers
+// can never alter the log through a returned value.
+func copyRevision(rev models.Revision) models.Revision {
+	changes := make([]models.FieldChange, len(rev.Changes))
+	for i, c := range rev.Changes {
+		changes[i] = models.FieldChange{
+			Field:  c.Field,
+			Before: append([]byte(nil), c.Before...),
+			After:  append([]byte(nil), c.After...),
+		}
+	}
+	rev.Changes = changes
+	rev.Book = cloneBook(rev.Book)
+	return rev
+}
@@ -0,0 +1,35 @@
+package repository
+
+import "testing"
+
+func TestBookRepo(t *testing.T) {
+	RunConformanceTests(t, func(t *testing.T) BookRepository { return NewBookRepository() })
+}
+
+func TestSQLiteBookRepo(t *testing.T) {
+	RunConformanceTests(t, func(t *testing.T) BookRepository { return newSQLite(t) })
+}
+
+func TestHistoryRepo(t *testing.T) {
+	RunHistoryConformanceTests(t, func(t *testing.T) HistoryRepository { return NewHistoryRepository() })
+}
+
+func TestSQLiteHistory(t *testing.T) {
+	RunHistoryConformanceTests(t, func(t *testing.T) HistoryRepository { return newSQLite(t).History() })
+}
+
+func TestAuthorRepo(t *testing.T) {
+	RunAuthorConformanceTests(t, func(t *testing.T) AuthorRepository { return NewAuthorRepository() })
+}
+
+func TestSQLiteAuthors(t *testing.T) {
+	RunAuthorConformanceTests(t, func(t *testing.T) AuthorRepository { return newSQLite(t).Authors() })
+}
+
+func TestWebhookRepo(t *testing.T) {
+	RunWebhookConformanceTests(t, func(t *testing.T) WebhookRepository { return NewWebhookRepository() })
+}
+
+func TestSQLiteWebhooks(t *testing.T) {
+	RunWebhookConformanceTests(t, func(t *testing.T) WebhookRepository { return newSQLite(t).Webhooks() })
+}
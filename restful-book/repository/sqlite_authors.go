@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+const authorColumns = `id, name, bio, owner_id`
+
+// Authors returns the authors stored in the same database as the books.
+func (s *SQLiteBookRepo) Authors() AuthorRepository {
+	return &sqliteAuthorRepo{db: s.db, refs: &s.authorRefs}
+}
+
+type sqliteAuthorRepo struct {
+	db *sql.DB
+	// refs orders DeleteFunc after Reference. A transaction cannot span
+	// the book write Reference runs, since the database has one connection.
+	refs *sync.RWMutex
+}
+
+// Create implements AuthorRepository.
+func (a *sqliteAuthorRepo) Create(author *models.Author) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := requireAuthorName(tx, *author); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO authors (`+authorColumns+`) VALUES (?, ?, ?, ?)`,
+		author.ID, author.Name, author.Bio, author.OwnerID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete implements AuthorRepository.
+func (a *sqliteAuthorRepo) Delete(id string) error {
+	res, err := a.db.Exec(`DELETE FROM authors WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return response.ErrAuthorNotFound
+	}
+	return nil
+}
+
+// DeleteFunc implements AuthorRepository.
+func (a *sqliteAuthorRepo) DeleteFunc(id string, fn func(author *models.Author) error) error {
+	a.refs.Lock()
+	defer a.refs.Unlock()
+	author, err := a.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := fn(author); err != nil {
+		return err
+	}
+	return a.Delete(id)
+}
+
+// Reference implements AuthorRepository.
+func (a *sqliteAuthorRepo) Reference(ids []string, fn func() error) error {
+	a.refs.RLock()
+	defer a.refs.RUnlock()
+	for _, id := range ids {
+		_, err := a.GetByID(id)
+		if errors.Is(err, response.ErrAuthorNotFound) {
+			return fmt.Errorf("%w: %s", response.ErrUnknownAuthor, id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return fn()
+}
+
+// GetAll implements AuthorRepository.
+func (a *sqliteAuthorRepo) GetAll() ([]*models.Author, error) {
+	rows, err := a.db.Query(`SELECT ` + authorColumns + ` FROM authors ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	authors := []*models.Author{}
+	for rows.Next() {
+		author, err := scanAuthor(rows)
+		if err != nil {
+			return nil, err
+		}
+		authors = append(authors, author)
+	}
+	return authors, rows.Err()
+}
+
+// GetByID implements AuthorRepository.
+func (a *sqliteAuthorRepo) GetByID(id string) (*models.Author, error) {
+	author, err := scanAuthor(a.db.QueryRow(`SELECT `+authorColumns+` FROM authors WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.ErrAuthorNotFound
+	}
+	return author, err
+}
+
+// UpdateFunc implements AuthorRepository.
+func (a *sqliteAuthorRepo) UpdateFunc(id string, fn func(author *models.Author) error) (*models.Author, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	author, err := scanAuthor(tx.QueryRow(`SELECT `+authorColumns+` FROM authors WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.ErrAuthorNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(author); err != nil {
+		return nil, err
+	}
+	author.ID = id
+	if err := requireAuthorName(tx, *author); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`UPDATE authors SET name = ?, bio = ?, owner_id = ? WHERE id = ?`,
+		author.Name, author.Bio, author.OwnerID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// requireAuthorName returns ErrAuthorExists if an author other than author
+// has its name.
+func requireAuthorName(tx *sql.Tx, author models.Author) error {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM authors WHERE name = ? AND id != ?)`,
+		author.Name, author.ID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return response.ErrAuthorExists
+	}
+	return nil
+}
+
+func scanAuthor(row scanner) (*models.Author, error) {
+	var author models.Author
+	if err := row.Scan(&author.ID, &author.Name, &author.Bio, &author.OwnerID); err != nil {
+		return nil, err
+	}
+	return &author, nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+const revisionColumns = `book_id, revision, action, actor, request_id, created_at, reverted_from, changes, book`
+
+// History returns the change log stored in the same database as the books.
+func (s *SQLiteBookRepo) History() HistoryRepository {
+	return &sqliteHistoryRepo{db: s.db}
+}
+
+type sqliteHistoryRepo struct {
+	db *sql.DB
+}
+
+// Append implements HistoryRepository.
+func (h *sqliteHistoryRepo) Append(rev models.Revision) error {
+	return appendRevision(h.db, rev)
+}
+
+// execer is the part of *sql.DB and *sql.Tx that appendRevision needs, so
+// a book write can log its revision in its own transaction.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func appendRevision(db execer, rev models.Revision) error {
+	changes, err := json.Marshal(rev.Changes)
+	if err != nil {
+		return err
+	}
+	book, err := json.Marshal(rev.Book)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO book_revisions (`+revisionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rev.BookID, rev.Revision, rev.Action, rev.Actor, rev.RequestID, rev.Timestamp.UnixNano(),
+		rev.RevertedFrom, string(changes), string(book))
+	if err != nil {
+		return fmt.Errorf("append revision %d of book %s: %w", rev.Revision, rev.BookID, err)
+	}
+	return nil
+}
+
+// List implements HistoryRepository.
+func (h *sqliteHistoryRepo) List(bookID string) ([]models.Revision, error) {
+	rows, err := h.db.Query(`SELECT `+revisionColumns+` FROM book_revisions WHERE book_id = ? ORDER BY revision`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.Revision
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, response.ErrBookNotFound
+	}
+	return revisions, nil
+}
+
+// Get implements HistoryRepository.
+func (h *sqliteHistoryRepo) Get(bookID string, revision int) (*models.Revision, error) {
+	row := h.db.QueryRow(`SELECT `+revisionColumns+` FROM book_revisions WHERE book_id = ? AND revision = ?`, bookID, revision)
+	rev, err := scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.ErrRevisionNotFound
+	}
+	return rev, err
+}
+
+func scanRevision(row scanner) (*models.Revision, error) {
+	var rev models.Revision
+	var createdAt int64
+	var changes, book string
+	err := row.Scan(&rev.BookID, &rev.Revision, &rev.Action, &rev.Actor, &rev.RequestID, &createdAt,
+		&rev.RevertedFrom, &changes, &book)
+	if err != nil {
+		return nil, err
+	}
+	rev.Timestamp = time.Unix(0, createdAt).UTC()
+	if err := json.Unmarshal([]byte(changes), &rev.Changes); err != nil {
+		return nil, fmt.Errorf("decode changes of book %s revision %d: %w", rev.BookID, rev.Revision, err)
+	}
+	if err := json.Unmarshal([]byte(book), &rev.Book); err != nil {
+		return nil, fmt.Errorf("decode book %s revision %d: %w", rev.BookID, rev.Revision, err)
+	}
+	return &rev, nil
+}
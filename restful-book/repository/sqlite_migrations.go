@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order and never edited once released; add a new
+// entry to change the schema.
+var migrations = []string{
+	`CREATE TABLE books (
+		seq            INTEGER PRIMARY KEY AUTOINCREMENT,
+		id             TEXT    NOT NULL UNIQUE,
+		title          TEXT    NOT NULL,
+		author         TEXT    NOT NULL,
+		published_year INTEGER NOT NULL DEFAULT 0,
+		isbn           TEXT    NOT NULL DEFAULT '',
+		description    TEXT    NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX idx_books_title_author ON books (title, author)`,
+	`ALTER TABLE books ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE books ADD COLUMN owner_id TEXT NOT NULL DEFAULT ''`,
+	// deleted_at holds Unix nanoseconds while a book is in the trash.
+	`ALTER TABLE books ADD COLUMN deleted_at INTEGER`,
+	// book_revisions is append-only; changes and book hold JSON and
+	// created_at Unix nanoseconds.
+	`CREATE TABLE book_revisions (
+		book_id       TEXT    NOT NULL,
+		revision      INTEGER NOT NULL,
+		action        TEXT    NOT NULL,
+		actor         TEXT    NOT NULL DEFAULT '',
+		request_id    TEXT    NOT NULL DEFAULT '',
+		created_at    INTEGER NOT NULL,
+		reverted_from INTEGER NOT NULL DEFAULT 0,
+		changes       TEXT    NOT NULL,
+		book          TEXT    NOT NULL,
+		PRIMARY KEY (book_id, revision)
+	)`,
+	// author_ids is a JSON array of author IDs in credit order.
+	`ALTER TABLE books ADD COLUMN author_ids TEXT NOT NULL DEFAULT '[]'`,
+	`CREATE TABLE authors (
+		seq      INTEGER PRIMARY KEY AUTOINCREMENT,
+		id       TEXT    NOT NULL UNIQUE,
+		name     TEXT    NOT NULL UNIQUE,
+		bio      TEXT    NOT NULL DEFAULT '',
+		owner_id TEXT    NOT NULL DEFAULT ''
+	)`,
+	// cover is a JSON models.Cover, NULL for books without a cover image.
+	`ALTER TABLE books ADD COLUMN cover TEXT`,
+	// events is a JSON array of event types; created_at holds Unix
+	// nanoseconds.
+	`CREATE TABLE webhooks (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT    NOT NULL UNIQUE,
+		url        TEXT    NOT NULL,
+		events     TEXT    NOT NULL DEFAULT '[]',
+		secret     TEXT    NOT NULL,
+		owner_id   TEXT    NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	)`,
+	// event holds a JSON models.Event and failed_at Unix nanoseconds.
+	`CREATE TABLE webhook_dead_letters (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT    NOT NULL UNIQUE,
+		webhook_id TEXT    NOT NULL,
+		event      TEXT    NOT NULL,
+		attempts   INTEGER NOT NULL,
+		last_error TEXT    NOT NULL DEFAULT '',
+		failed_at  INTEGER NOT NULL
+	)`,
+	`CREATE INDEX idx_webhook_dead_letters_webhook ON webhook_dead_letters (webhook_id, seq)`,
+}
+
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,498 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	_ "modernc.org/sqlite"
+)
+
+const bookColumns = `id, title, author, published_year, isbn, description, version, owner_id, deleted_at, author_ids, cover`
+
+// active restricts a query to books that are not in the trash.
+const active = `deleted_at IS NULL`
+
+// bookAssignments is the SET clause shared by Update and UpdateFunc; the
+// version is handled separately by each.
+const bookAssignments = `id = ?, title = ?, author = ?, published_year = ?, isbn = ?, description = ?, owner_id = ?, author_ids = ?, cover = ?`
+
+func bookValues(book models.Book) []any {
+	return []any{book.ID, book.Title, book.Author, book.PublishedYear, book.ISBN, book.Description, book.OwnerID, authorIDs(book.AuthorIDs), coverColumn(book.Cover)}
+}
+
+// authorIDs encodes the author_ids column, a JSON array of strings.
+func authorIDs(ids []string) string {
+	if len(ids) == 0 {
+		return `[]`
+	}
+	data, _ := json.Marshal(ids)
+	return string(data)
+}
+
+// coverColumn encodes the cover column, NULL when there is no cover.
+func coverColumn(cover *models.Cover) any {
+	if cover == nil {
+		return nil
+	}
+	data, _ := json.Marshal(cover)
+	return string(data)
+}
+
+// NewSQLiteBookRepository opens (or creates) the SQLite database at path and
+// brings its schema up to date.
+func NewSQLiteBookRepository(path string) (*SQLiteBookRepo, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite %q: %w", path, err)
+	}
+	// A single connection serialises writers and keeps the duplicate check in
+	// Create atomic without relying on SQLite's busy handling.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`PRAGMA journal_mode = WAL; PRAGMA foreign_keys = ON`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("configure sqlite: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteBookRepo{db: db}, nil
+}
+
+type SQLiteBookRepo struct {
+	db *sql.DB
+	// authorRefs is shared by the repositories Authors returns.
+	authorRefs sync.RWMutex
+}
+
+// Close releases the underlying database handle.
+func (s *SQLiteBookRepo) Close() error {
+	return s.db.Close()
+}
+
+// sqliteLoggedRepo is the SQLiteBookRepo returned by Logged.
+type sqliteLoggedRepo struct {
+	*SQLiteBookRepo
+	record Recorder
+}
+
+// Logged implements BookRepository.
+func (s *SQLiteBookRepo) Logged(record Recorder) BookRepository {
+	return &sqliteLoggedRepo{SQLiteBookRepo: s, record: record}
+}
+
+// logRevision appends the revision record builds for a change as part of
+// tx, if record is set.
+func logRevision(tx *sql.Tx, record Recorder, before, after models.Book) error {
+	if record == nil {
+		return nil
+	}
+	return appendRevision(tx, record(before, after))
+}
+
+// Create implements BookRepository.
+func (s *SQLiteBookRepo) Create(book *models.Book) error {
+	return s.create(book, nil)
+}
+
+// Create implements BookRepository.
+func (l *sqliteLoggedRepo) Create(book *models.Book) error {
+	return l.create(book, l.record)
+}
+
+func (s *SQLiteBookRepo) create(book *models.Book, record Recorder) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := requireUnique(tx, *book); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO books (`+bookColumns+`) VALUES (?, ?, ?, ?, ?, ?, 1, ?, NULL, ?, ?)`,
+		book.ID, book.Title, book.Author, book.PublishedYear, book.ISBN, book.Description, book.OwnerID, authorIDs(book.AuthorIDs), coverColumn(book.Cover))
+	if err != nil {
+		return err
+	}
+	created := *book
+	created.Version = 1
+	created.DeletedAt = nil
+	if err := logRevision(tx, record, models.Book{}, created); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	book.Version = 1
+	book.DeletedAt = nil
+	return nil
+}
+
+// requireUnique returns ErrBookAlreadyExist if an active book other than
+// book is the same work.
+func requireUnique(tx *sql.Tx, book models.Book) error {
+	rows, err := tx.Query(`SELECT `+bookColumns+` FROM books WHERE title = ? AND id != ? AND `+active,
+		book.Title, book.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		other, err := scanBook(rows)
+		if err != nil {
+			return err
+		}
+		if other.SameWork(book) {
+			return response.ErrBookAlreadyExist
+		}
+	}
+	return rows.Err()
+}
+
+// Delete implements BookRepository.
+func (s *SQLiteBookRepo) Delete(id string, version int) (*models.Book, error) {
+	return s.delete(id, version, nil)
+}
+
+// Delete implements BookRepository.
+func (l *sqliteLoggedRepo) Delete(id string, version int) (*models.Book, error) {
+	return l.delete(id, version, l.record)
+}
+
+func (s *SQLiteBookRepo) delete(id string, version int, record Recorder) (*models.Book, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	book, err := scanBook(tx.QueryRow(`SELECT `+bookColumns+` FROM books WHERE id = ? AND `+active, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && book.Version != version {
+		return nil, response.ErrVersionMismatch
+	}
+	now := time.Now()
+	_, err = tx.Exec(`UPDATE books SET deleted_at = ?, version = version + 1 WHERE id = ?`,
+		now.UnixNano(), id)
+	if err != nil {
+		return nil, err
+	}
+	before := *book
+	deletedAt := time.Unix(0, now.UnixNano()).UTC()
+	book.DeletedAt = &deletedAt
+	book.Version++
+	if err := logRevision(tx, record, before, *book); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// Trash implements BookRepository.
+func (s *SQLiteBookRepo) Trash() ([]*models.Book, error) {
+	return s.query(`SELECT ` + bookColumns + ` FROM books WHERE deleted_at IS NOT NULL ORDER BY seq`)
+}
+
+// Restore implements BookRepository.
+func (s *SQLiteBookRepo) Restore(id string) (*models.Book, error) {
+	return s.restore(id, nil)
+}
+
+// Restore implements BookRepository.
+func (l *sqliteLoggedRepo) Restore(id string) (*models.Book, error) {
+	return l.restore(id, l.record)
+}
+
+func (s *SQLiteBookRepo) restore(id string, record Recorder) (*models.Book, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	book, err := scanBook(tx.QueryRow(`SELECT `+bookColumns+` FROM books WHERE id = ? AND deleted_at IS NOT NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := requireUnique(tx, *book); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE books SET deleted_at = NULL, version = version + 1 WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	before := *book
+	book.DeletedAt = nil
+	book.Version++
+	if err := logRevision(tx, record, before, *book); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// Purge implements BookRepository.
+func (s *SQLiteBookRepo) Purge(before time.Time) ([]*models.Book, error) {
+	return s.query(`DELETE FROM books WHERE deleted_at IS NOT NULL AND deleted_at < ? RETURNING `+bookColumns, before.UnixNano())
+}
+
+// GetAll implements BookRepository.
+func (s *SQLiteBookRepo) GetAll() ([]*models.Book, error) {
+	books, err := s.query(`SELECT ` + bookColumns + ` FROM books WHERE ` + active + ` ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	if len(books) == 0 {
+		return nil, response.ErrNoBooks
+	}
+	return books, nil
+}
+
+// GetByID implements BookRepository.
+func (s *SQLiteBookRepo) GetByID(id string) (*models.Book, error) {
+	row := s.db.QueryRow(`SELECT `+bookColumns+` FROM books WHERE id = ? AND `+active, id)
+	book, err := scanBook(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// Query implements BookRepository.
+func (s *SQLiteBookRepo) Query(q models.BookQuery) ([]*models.Book, int, error) {
+	if err := s.requireBooks(); err != nil {
+		return nil, 0, err
+	}
+
+	where := []string{active}
+	var args []any
+	if q.Title != "" {
+		where = append(where, `title = ?`)
+		args = append(args, q.Title)
+	}
+	if q.Author != "" {
+		where = append(where, `author = ?`)
+		args = append(args, q.Author)
+	}
+	if q.AuthorID != "" {
+		where = append(where, `EXISTS (SELECT 1 FROM json_each(books.author_ids) WHERE json_each.value = ?)`)
+		args = append(args, q.AuthorID)
+	}
+	if q.YearFrom != 0 {
+		where = append(where, `published_year >= ?`)
+		args = append(args, q.YearFrom)
+	}
+	if q.YearTo != 0 {
+		where = append(where, `published_year <= ?`)
+		args = append(args, q.YearTo)
+	}
+	if q.ISBNPrefix != "" {
+		// substr rather than LIKE: LIKE is case-insensitive and treats % and _
+		// as wildcards.
+		where = append(where, `substr(isbn, 1, length(?)) = ?`)
+		args = append(args, q.ISBNPrefix, q.ISBNPrefix)
+	}
+	filter := ` WHERE ` + strings.Join(where, ` AND `)
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM books`+filter, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	var order []string
+	for _, f := range q.Sort {
+		if !models.BookSortFields[f.Field] {
+			return nil, 0, fmt.Errorf("%w: cannot sort by %q", response.ErrInvalidQuery, f.Field)
+		}
+		if f.Desc {
+			order = append(order, f.Field+` DESC`)
+		} else {
+			order = append(order, f.Field)
+		}
+	}
+	order = append(order, `seq`)
+
+	limit := -1
+	if q.Limit > 0 {
+		limit = q.Limit
+	}
+	books, err := s.query(`SELECT `+bookColumns+` FROM books`+filter+
+		` ORDER BY `+strings.Join(order, `, `)+` LIMIT ? OFFSET ?`,
+		append(args, limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return books, total, nil
+}
+
+// ListAfter implements BookRepository.
+func (s *SQLiteBookRepo) ListAfter(after string, limit int) ([]*models.Book, error) {
+	if limit <= 0 {
+		limit = -1 // no limit in SQLite
+	}
+	return s.query(`SELECT `+bookColumns+` FROM books WHERE id > ? AND `+active+` ORDER BY id LIMIT ?`, after, limit)
+}
+
+// SearchByAuthor implements BookRepository.
+func (s *SQLiteBookRepo) SearchByAuthor(author string) ([]*models.Book, error) {
+	return s.search(`author`, author)
+}
+
+// SearchByTitle implements BookRepository.
+func (s *SQLiteBookRepo) SearchByTitle(title string) ([]*models.Book, error) {
+	return s.search(`title`, title)
+}
+
+// Update implements BookRepository.
+func (s *SQLiteBookRepo) Update(id string, book models.Book) error {
+	_, err := s.updateFunc(id, replaceWith(book), nil)
+	return err
+}
+
+// Update implements BookRepository.
+func (l *sqliteLoggedRepo) Update(id string, book models.Book) error {
+	_, err := l.updateFunc(id, replaceWith(book), l.record)
+	return err
+}
+
+// UpdateFunc implements BookRepository.
+func (s *SQLiteBookRepo) UpdateFunc(id string, fn func(book *models.Book) error) (*models.Book, error) {
+	return s.updateFunc(id, fn, nil)
+}
+
+// UpdateFunc implements BookRepository.
+func (l *sqliteLoggedRepo) UpdateFunc(id string, fn func(book *models.Book) error) (*models.Book, error) {
+	return l.updateFunc(id, fn, l.record)
+}
+
+func (s *SQLiteBookRepo) updateFunc(id string, fn func(book *models.Book) error, record Recorder) (*models.Book, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	book, err := scanBook(tx.QueryRow(`SELECT `+bookColumns+` FROM books WHERE id = ? AND `+active, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	before := *book
+	before.AuthorIDs = slices.Clone(book.AuthorIDs)
+	if err := fn(book); err != nil {
+		return nil, err
+	}
+	book.Version = before.Version + 1
+	book.DeletedAt = nil
+
+	_, err = tx.Exec(`UPDATE books SET `+bookAssignments+`, version = ? WHERE id = ?`,
+		append(bookValues(*book), book.Version, id)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := logRevision(tx, record, before, *book); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	updated := *book
+	return &updated, nil
+}
+
+// search mirrors BookRepo: ErrNoBooks only when no book is active, an
+// empty slice when nothing matches.
+func (s *SQLiteBookRepo) search(column, value string) ([]*models.Book, error) {
+	if err := s.requireBooks(); err != nil {
+		return nil, err
+	}
+	return s.query(`SELECT `+bookColumns+` FROM books WHERE `+column+` = ? AND `+active+` ORDER BY seq`, value)
+}
+
+func (s *SQLiteBookRepo) requireBooks() error {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM books WHERE ` + active + `)`).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return response.ErrNoBooks
+	}
+	return nil
+}
+
+func (s *SQLiteBookRepo) query(query string, args ...any) ([]*models.Book, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []*models.Book{}
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	return books, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBook(row scanner) (*models.Book, error) {
+	var book models.Book
+	var deletedAt sql.NullInt64
+	var ids string
+	var cover sql.NullString
+	err := row.Scan(&book.ID, &book.Title, &book.Author, &book.PublishedYear, &book.ISBN, &book.Description, &book.Version, &book.OwnerID, &deletedAt, &ids, &cover)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(ids), &book.AuthorIDs); err != nil {
+		return nil, fmt.Errorf("decode author_ids of book %s: %w", book.ID, err)
+	}
+	if len(book.AuthorIDs) == 0 {
+		book.AuthorIDs = nil
+	}
+	if deletedAt.Valid {
+		t := time.Unix(0, deletedAt.Int64).UTC()
+		book.DeletedAt = &t
+	}
+	if cover.Valid {
+		book.Cover = new(models.Cover)
+		if err := json.Unmarshal([]byte(cover.String), book.Cover); err != nil {
+			return nil, fmt.Errorf("decode cover of book %s: %w", book.ID, err)
+		}
+	}
+	return &book, nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// newSQLite opens a SQLite repository in a temporary directory that is
+// removed when t ends.
+func newSQLite(t *testing.T) *SQLiteBookRepo {
+	t.Helper()
+	return openSQLite(t, filepath.Join(t.TempDir(), "books.db"))
+}
+
+func openSQLite(t *testing.T, path string) *SQLiteBookRepo {
+	t.Helper()
+	repo, err := NewSQLiteBookRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func schemaVersion(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.db")
+
+	// A database left at the first migration, with a book in it.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY)`,
+		migrations[0],
+		`INSERT INTO schema_migrations (version) VALUES (1)`,
+		`INSERT INTO books (id, title, author) VALUES ('old', 'Old Title', 'Old Author')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	db.Close()
+
+	repo := openSQLite(t, path)
+	if v := schemaVersion(t, repo.db); v != len(migrations) {
+		t.Fatalf("schema version = %d, want %d", v, len(migrations))
+	}
+	got, err := repo.GetByID("old")
+	if err != nil {
+		t.Fatalf("GetByID after migrating: %v", err)
+	}
+	if got.Title != "Old Title" || got.Author != "Old Author" {
+		t.Fatalf("GetByID after migrating = %+v", got)
+	}
+	repo.Close()
+
+	// Opening an up-to-date database applies nothing.
+	repo = openSQLite(t, path)
+	if v := schemaVersion(t, repo.db); v != len(migrations) {
+		t.Fatalf("schema version after reopening = %d, want %d", v, len(migrations))
+	}
+}
+
+func TestSQLitePersistsBooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.db")
+	book := models.Book{
+		ID:            "6f1c1c52-3c4e-4f5e-9d8a-2b7f0e1a9c3d",
+		Title:         "The Go Programming Language",
+		Author:        "Donovan",
+		PublishedYear: 2015,
+		ISBN:          "978-0134190440",
+		Description:   "It's a book; \"quoted\" and 'quoted'.",
+	}
+	repo := openSQLite(t, path)
+	if err := repo.Create(&book); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	// IDs and fields survive a restart, and so does the duplicate check.
+	repo = openSQLite(t, path)
+	got, err := repo.GetByID(book.ID)
+	if err != nil {
+		t.Fatalf("GetByID after reopening: %v", err)
+	}
+	if got.ID != book.ID || got.Title != book.Title || got.Author != book.Author ||
+		got.PublishedYear != book.PublishedYear || got.ISBN != book.ISBN || got.Description != book.Description {
+		t.Fatalf("GetByID after reopening = %+v, want %+v", got, book)
+	}
+	if _, err := repo.GetByID("6F1C1C52-3C4E-4F5E-9D8A-2B7F0E1A9C3D"); !errors.Is(err, response.ErrBookNotFound) {
+		t.Fatalf("GetByID(upper-case ID) = %v, want ErrBookNotFound", err)
+	}
+
+	dup := models.Book{ID: "another", Title: book.Title, Author: book.Author}
+	if err := repo.Create(&dup); !errors.Is(err, response.ErrBookAlreadyExist) {
+		t.Fatalf("Create(duplicate) = %v, want ErrBookAlreadyExist", err)
+	}
+	other := models.Book{ID: "other", Title: book.Title, Author: "Kernighan"}
+	if err := repo.Create(&other); err != nil {
+		t.Fatalf("Create(same title, other author): %v", err)
+	}
+	books, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 2 || books[0].ID != book.ID || books[1].ID != other.ID {
+		t.Fatalf("GetAll = %+v, want %s then %s", books, book.ID, other.ID)
+	}
+}
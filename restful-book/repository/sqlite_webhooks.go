@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+const (
+	webhookColumns    = `id, url, events, secret, owner_id, created_at`
+	deadLetterColumns = `id, webhook_id, event, attempts, last_error, failed_at`
+)
+
+// Webhooks returns the webhooks stored in the same database as the books.
+func (s *SQLiteBookRepo) Webhooks() WebhookRepository {
+	return &sqliteWebhookRepo{db: s.db}
+}
+
+type sqliteWebhookRepo struct {
+	db *sql.DB
+}
+
+// Create implements WebhookRepository.
+func (wr *sqliteWebhookRepo) Create(webhook *models.Webhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+	_, err = wr.db.Exec(`INSERT INTO webhooks (`+webhookColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		webhook.ID, webhook.URL, string(events), webhook.Secret, webhook.OwnerID, webhook.CreatedAt.UnixNano())
+	return err
+}
+
+// Delete implements WebhookRepository.
+func (wr *sqliteWebhookRepo) Delete(id string) error {
+	tx, err := wr.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return response.ErrWebhookNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM webhook_dead_letters WHERE webhook_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetAll implements WebhookRepository.
+func (wr *sqliteWebhookRepo) GetAll() ([]*models.Webhook, error) {
+	rows, err := wr.db.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetByID implements WebhookRepository.
+func (wr *sqliteWebhookRepo) GetByID(id string) (*models.Webhook, error) {
+	webhook, err := scanWebhook(wr.db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+// AddDeadLetter implements WebhookRepository.
+func (wr *sqliteWebhookRepo) AddDeadLetter(letter models.DeadLetter) error {
+	event, err := json.Marshal(letter.Event)
+	if err != nil {
+		return err
+	}
+	tx, err := wr.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := requireWebhook(tx, letter.WebhookID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO webhook_dead_letters (`+deadLetterColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		letter.ID, letter.WebhookID, string(event), letter.Attempts, letter.LastError, letter.FailedAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeadLetters implements WebhookRepository.
+func (wr *sqliteWebhookRepo) DeadLetters(webhookID string) ([]models.DeadLetter, error) {
+	tx, err := wr.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := requireWebhook(tx, webhookID); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(`SELECT `+deadLetterColumns+` FROM webhook_dead_letters WHERE webhook_id = ? ORDER BY seq`, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []models.DeadLetter{}
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, *letter)
+	}
+	return letters, rows.Err()
+}
+
+// requireWebhook returns ErrWebhookNotFound unless the webhook exists.
+func requireWebhook(tx *sql.Tx, id string) error {
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return response.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func scanWebhook(row scanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	var events string
+	var createdAt int64
+	err := row.Scan(&webhook.ID, &webhook.URL, &events, &webhook.Secret, &webhook.OwnerID, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	webhook.CreatedAt = time.Unix(0, createdAt).UTC()
+	if err := json.Unmarshal([]byte(events), &webhook.Events); err != nil {
+		return nil, fmt.Errorf("decode events of webhook %s: %w", webhook.ID, err)
+	}
+	return &webhook, nil
+}
+
+func scanDeadLetter(row scanner) (*models.DeadLetter, error) {
+	var letter models.DeadLetter
+	var event string
+	var failedAt int64
+	err := row.Scan(&letter.ID, &letter.WebhookID, &event, &letter.Attempts, &letter.LastError, &failedAt)
+	if err != nil {
+		return nil, err
+	}
+	letter.FailedAt = time.Unix(0, failedAt).UTC()
+	if err := json.Unmarshal([]byte(event), &letter.Event); err != nil {
+		return nil, fmt.Errorf("decode event of dead letter %s: %w", letter.ID, err)
+	}
+	return &letter, nil
+}
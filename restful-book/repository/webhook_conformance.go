@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// RunWebhookConformanceTests is the RunConformanceTests counterpart for
+// WebhookRepository implementations. newRepo must return a fresh, empty
+// repository on every call.
+func RunWebhookConformanceTests(t *testing.T, newRepo func(t *testing.T) WebhookRepository) {
+	t.Helper()
+	runConformance(t, newRepo, []conformanceTest[WebhookRepository]{
+		{"NotFound", testWebhooksNotFound},
+		{"GetAllInCreationOrder", testWebhooksGetAllInCreationOrder},
+		{"DeadLettersOldestFirst", testWebhooksDeadLettersOldestFirst},
+		{"DeleteDropsDeadLetters", testWebhooksDeleteDropsDeadLetters},
+	})
+}
+
+func conformanceWebhook(n int) models.Webhook {
+	webhook := models.Webhook{
+		ID:        fmt.Sprintf("webhook-%d", n),
+		URL:       fmt.Sprintf("https://example.com/hooks/%d", n),
+		Secret:    fmt.Sprintf("secret-%d", n),
+		OwnerID:   fmt.Sprintf("owner-%d", n%3),
+		CreatedAt: time.Date(2024, 1, n, 12, 0, 0, 0, time.UTC),
+	}
+	if n%2 == 1 {
+		webhook.Events = []string{models.EventBookCreated, models.EventBookDeleted}
+	}
+	return webhook
+}
+
+func conformanceDeadLetter(webhookID string, n int) models.DeadLetter {
+	return models.DeadLetter{
+		ID:        fmt.Sprintf("%s-letter-%d", webhookID, n),
+		WebhookID: webhookID,
+		Event: models.Event{
+			ID:        fmt.Sprintf("event-%d", n),
+			Type:      models.EventBookUpdated,
+			Timestamp: time.Date(2024, 2, n, 8, 30, 0, 0, time.UTC),
+			Book:      conformanceBook(n),
+		},
+		Attempts:  n + 1,
+		LastError: fmt.Sprintf("attempt %d failed", n+1),
+		FailedAt:  time.Date(2024, 2, n, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func mustCreateWebhook(t *testing.T, repo WebhookRepository, webhook models.Webhook) {
+	t.Helper()
+	if err := repo.Create(&webhook); err != nil {
+		t.Fatalf("Create(%q): %v", webhook.ID, err)
+	}
+}
+
+func testWebhooksNotFound(t *testing.T, repo WebhookRepository) {
+	webhooks, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectEqual(t, "GetAll", webhooks, []*models.Webhook{})
+	_, err = repo.GetByID("missing")
+	expectErr(t, "GetByID", err, response.ErrWebhookNotFound)
+	expectErr(t, "Delete", repo.Delete("missing"), response.ErrWebhookNotFound)
+	expectErr(t, "AddDeadLetter", repo.AddDeadLetter(conformanceDeadLetter("missing", 1)), response.ErrWebhookNotFound)
+	_, err = repo.DeadLetters("missing")
+	expectErr(t, "DeadLetters", err, response.ErrWebhookNotFound)
+}
+
+func testWebhooksGetAllInCreationOrder(t *testing.T, repo WebhookRepository) {
+	var want []models.Webhook
+	for n := 3; n > 0; n-- {
+		webhook := conformanceWebhook(n)
+		mustCreateWebhook(t, repo, webhook)
+		want = append(want, webhook)
+	}
+
+	got, err := repo.GetByID(want[0].ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	expectEqual(t, "GetByID", *got, want[0])
+	got.Events[0] = "mutated after GetByID"
+
+	webhooks, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectAll(t, "GetAll", webhooks, want...)
+}
+
+func testWebhooksDeadLettersOldestFirst(t *testing.T, repo WebhookRepository) {
+	first, second := conformanceWebhook(1), conformanceWebhook(2)
+	mustCreateWebhook(t, repo, first)
+	mustCreateWebhook(t, repo, second)
+
+	letters, err := repo.DeadLetters(first.ID)
+	if err != nil {
+		t.Fatalf("DeadLetters: %v", err)
+	}
+	expectEqual(t, "DeadLetters", letters, []models.DeadLetter{})
+
+	var want []models.DeadLetter
+	for n := 1; n <= 3; n++ {
+		letter := conformanceDeadLetter(first.ID, n)
+		if err := repo.AddDeadLetter(letter); err != nil {
+			t.Fatalf("AddDeadLetter: %v", err)
+		}
+		want = append(want, letter)
+	}
+	if err := repo.AddDeadLetter(conformanceDeadLetter(second.ID, 1)); err != nil {
+		t.Fatalf("AddDeadLetter: %v", err)
+	}
+
+	letters, err = repo.DeadLetters(first.ID)
+	if err != nil {
+		t.Fatalf("DeadLetters: %v", err)
+	}
+	expectEqual(t, "DeadLetters", letters, want)
+	letters[0].Event.Book.AuthorIDs[0] = "mutated after DeadLetters"
+
+	letters, err = repo.DeadLetters(first.ID)
+	if err != nil {
+		t.Fatalf("DeadLetters: %v", err)
+	}
+	expectEqual(t, "DeadLetters after mutation", letters, want)
+}
+
+func testWebhooksDeleteDropsDeadLetters(t *testing.T, repo WebhookRepository) {
+	first, second := conformanceWebhook(1), conformanceWebhook(2)
+	mustCreateWebhook(t, repo, first)
+	mustCreateWebhook(t, repo, second)
+	if err := repo.AddDeadLetter(conformanceDeadLetter(first.ID, 1)); err != nil {
+		t.Fatalf("AddDeadLetter: %v", err)
+	}
+
+	if err := repo.Delete(first.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, err := repo.GetByID(first.ID)
+	expectErr(t, "GetByID after Delete", err, response.ErrWebhookNotFound)
+	_, err = repo.DeadLetters(first.ID)
+	expectErr(t, "DeadLetters after Delete", err, response.ErrWebhookNotFound)
+	expectErr(t, "Delete twice", repo.Delete(first.ID), response.ErrWebhookNotFound)
+	// A delivery that fails after the webhook was deleted has nowhere to go.
+	expectErr(t, "AddDeadLetter after Delete", repo.AddDeadLetter(conformanceDeadLetter(first.ID, 2)), response.ErrWebhookNotFound)
+
+	webhooks, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	expectAll(t, "GetAll after Delete", webhooks, second)
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+// WebhookRepository stores webhook subscriptions along with the events that
+// could not be delivered to them.
+type WebhookRepository interface {
+	// GetAll returns every webhook in creation order; an empty repository
+	// yields an empty slice.
+	GetAll() ([]*models.Webhook, error)
+	GetByID(id string) (*models.Webhook, error)
+	Create(webhook *models.Webhook) error
+	// Delete removes the webhook and its dead letters.
+	Delete(id string) error
+	// AddDeadLetter records an event that could not be delivered. It
+	// returns ErrWebhookNotFound if the webhook has been deleted.
+	AddDeadLetter(letter models.DeadLetter) error
+	// DeadLetters returns the dead letters of a webhook, oldest first.
+	DeadLetters(webhookID string) ([]models.DeadLetter, error)
+}
+
+func NewWebhookRepository() WebhookRepository {
+	return &WebhookRepo{deadLetters: map[string][]models.DeadLetter{}}
+}
+
+type WebhookRepo struct {
+	webhooks    []models.Webhook
+	deadLetters map[string][]models.DeadLetter
+	mu          sync.RWMutex
+}
+
+// Create implements WebhookRepository.
+func (wr *WebhookRepo) Create(webhook *models.Webhook) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.webhooks = append(wr.webhooks, cloneWebhook(*webhook))
+	return nil
+}
+
+// Delete implements WebhookRepository.
+func (wr *WebhookRepo) Delete(id string) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	i := wr.index(id)
+	if i < 0 {
+		return response.ErrWebhookNotFound
+	}
+	wr.webhooks = slices.Delete(wr.webhooks, i, i+1)
+	delete(wr.deadLetters, id)
+	return nil
+}
+
+// GetAll implements WebhookRepository.
+func (wr *WebhookRepo) GetAll() ([]*models.Webhook, error) {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+
+	webhooks := make([]*models.Webhook, 0, len(wr.webhooks))
+	for _, webhook := range wr.webhooks {
+		data := cloneWebhook(webhook)
+		webhooks = append(webhooks, &data)
+	}
+	return webhooks, nil
+}
+
+// GetByID implements WebhookRepository.
+func (wr *WebhookRepo) GetByID(id string) (*models.Webhook, error) {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+	i := wr.index(id)
+	if i < 0 {
+		return nil, response.ErrWebhookNotFound
+	}
+	data := cloneWebhook(wr.webhooks[i])
+	return &data, nil
+}
+
+// AddDeadLetter implements WebhookRepository.
+func (wr *WebhookRepo) AddDeadLetter(letter models.DeadLetter) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	if wr.index(letter.WebhookID) < 0 {
+		return response.ErrWebhookNotFound
+	}
+	letter.Event.Book = cloneBook(letter.Event.Book)
+	wr.deadLetters[letter.WebhookID] = append(wr.deadLetters[letter.WebhookID], letter)
+	return nil
+}
+
+// DeadLetters implements WebhookRepository.
+func (wr *WebhookRepo) DeadLetters(webhookID string) ([]models.DeadLetter, error) {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+	if wr.index(webhookID) < 0 {
+		return nil, response.ErrWebhookNotFound
+	}
+	letters := make([]models.DeadLetter, 0, len(wr.deadLetters[webhookID]))
+	for _, letter := range wr.deadLetters[webhookID] {
+		letter.Event.Book = cloneBook(letter.Event.Book)
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+func (wr *WebhookRepo) index(id string) int {
+	return slices.IndexFunc(wr.webhooks, func(w models.Webhook) bool { return w.ID == id })
+}
+
+func cloneWebhook(webhook models.Webhook) models.Webhook {
+	webhook.Events = slices.Clone(webhook.Events)
+	return webhook
+}
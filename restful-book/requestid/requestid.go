@@ -0,0 +1,18 @@
+// Package requestid carries the ID of the request being served through its
+// context, so the layers below the HTTP handlers can log and record it.
+package requestid
+
+import "context"
+
+type key struct{}
+
+// With returns a copy of ctx carrying id.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// From returns the request ID stored by With, or "".
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(key{}).(string)
+	return id
+}
@@ -0,0 +1,167 @@
+// Package search implements an in-memory inverted index for full-text search
+// over book titles, authors and descriptions.
+package search
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+)
+
+// Field weights: a hit in the title counts more than one in the description.
+const (
+	titleWeight       = 3.0
+	authorWeight      = 2.0
+	descriptionWeight = 1.0
+
+	// prefixPenalty scales the score of a term that only matched as a prefix,
+	// so "prog" ranks "programming" below an exact "prog".
+	prefixPenalty = 0.5
+)
+
+type Result struct {
+	ID    string
+	Score float64
+}
+
+// Index maps terms to the books containing them. It is safe for concurrent
+// use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[string]float64 // term -> book ID -> weighted frequency
+	docs     map[string][]string           // book ID -> distinct terms, for removal
+	terms    []string                      // sorted keys of postings, for prefix lookups
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: map[string]map[string]float64{},
+		docs:     map[string][]string{},
+	}
+}
+
+// Add indexes book, replacing any previous entry with the same ID.
+func (ix *Index) Add(book models.Book) {
+	weights := map[string]float64{}
+	for _, f := range []struct {
+		text   string
+		weight float64
+	}{
+		{book.Title, titleWeight},
+		{book.Author, authorWeight},
+		{book.Description, descriptionWeight},
+	} {
+		for _, term := range Tokenize(f.text) {
+			weights[term] += f.weight
+		}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(book.ID)
+
+	terms := make([]string, 0, len(weights))
+	for term, w := range weights {
+		docs, ok := ix.postings[term]
+		if !ok {
+			docs = map[string]float64{}
+			ix.postings[term] = docs
+			i, _ := slices.BinarySearch(ix.terms, term)
+			ix.terms = slices.Insert(ix.terms, i, term)
+		}
+		docs[book.ID] = w
+		terms = append(terms, term)
+	}
+	ix.docs[book.ID] = terms
+}
+
+// Remove drops the book with the given ID from the index.
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id string) {
+	for _, term := range ix.docs[id] {
+		docs := ix.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(ix.postings, term)
+			if i, ok := slices.BinarySearch(ix.terms, term); ok {
+				ix.terms = slices.Delete(ix.terms, i, i+1)
+			}
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// Search returns the books matching every term of query, best match first.
+// Each query term matches indexed terms it equals or is a prefix of.
+func (ix *Index) Search(query string) []Result {
+	queryTerms := Tokenize(query)
+	if len(queryTerms) == 0 {
+		return nil
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	n := float64(len(ix.docs))
+	var scores map[string]float64
+	for _, qt := range queryTerms {
+		termScores := map[string]float64{}
+		i, _ := slices.BinarySearch(ix.terms, qt)
+		for ; i < len(ix.terms) && strings.HasPrefix(ix.terms[i], qt); i++ {
+			term := ix.terms[i]
+			docs := ix.postings[term]
+			idf := math.Log(1 + n/float64(len(docs)))
+			penalty := 1.0
+			if term != qt {
+				penalty = prefixPenalty
+			}
+			for id, w := range docs {
+				termScores[id] = max(termScores[id], w*idf*penalty)
+			}
+		}
+
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for id, s := range scores {
+			if ts, ok := termScores[id]; ok {
+				scores[id] = s + ts
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, s := range scores {
+		results = append(results, Result{ID: id, Score: s})
+	}
+	slices.SortFunc(results, func(a, b Result) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return results
+}
+
+// Tokenize splits text into lower-cased terms on anything that is not a
+// letter or digit.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
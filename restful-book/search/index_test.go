@@ -0,0 +1,85 @@
+package search
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+)
+
+func TestTokenize(t *testing.T) {
+	for text, want := range map[string][]string{
+		"":                      nil,
+		"The Go Programming":    {"the", "go", "programming"},
+		"C++, 2nd ed. (1988)":   {"c", "2nd", "ed", "1988"},
+		"Ünïcode—dash_under":    {"ünïcode", "dash", "under"},
+		"  --  ":                nil,
+		"Kernighan & Ritchie's": {"kernighan", "ritchie", "s"},
+	} {
+		if got := Tokenize(text); !slices.Equal(got, want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func ids(results []Result) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.Add(models.Book{ID: "gopl", Title: "The Go Programming Language", Author: "Donovan"})
+	ix.Add(models.Book{ID: "prog", Title: "Prog Rock", Author: "Someone", Description: "go listen"})
+	ix.Add(models.Book{ID: "kr", Title: "The C Programming Language", Author: "Kernighan"})
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"missing", []string{}},
+		// A title hit outweighs a description hit.
+		{"go", []string{"gopl", "prog"}},
+		// Every term must match.
+		{"programming language", []string{"gopl", "kr"}},
+		{"go kernighan", []string{}},
+		// An exact term outranks one it is only a prefix of.
+		{"prog", []string{"prog", "gopl", "kr"}},
+		{"KERN", []string{"kr"}},
+	} {
+		if got := ids(ix.Search(tc.query)); !slices.Equal(got, tc.want) {
+			t.Errorf("Search(%q) = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestAddReplacesAndRemove(t *testing.T) {
+	ix := NewIndex()
+	ix.Add(models.Book{ID: "1", Title: "Old title"})
+	ix.Add(models.Book{ID: "2", Title: "Old news"})
+	ix.Add(models.Book{ID: "1", Title: "New title"})
+
+	if got := ids(ix.Search("old")); !slices.Equal(got, []string{"2"}) {
+		t.Fatalf("Search(old) after re-adding 1 = %q, want [2]", got)
+	}
+	if got := ids(ix.Search("title")); !slices.Equal(got, []string{"1"}) {
+		t.Fatalf("Search(title) = %q, want [1]", got)
+	}
+
+	ix.Remove("1")
+	ix.Remove("unknown")
+	if got := ids(ix.Search("title")); len(got) != 0 {
+		t.Fatalf("Search(title) after Remove = %q, want none", got)
+	}
+	if len(ix.terms) != len(ix.postings) || slices.Contains(ix.terms, "new") {
+		t.Fatalf("terms of removed books are still indexed: %q", ix.terms)
+	}
+	if got := ids(ix.Search("old")); !slices.Equal(got, []string{"2"}) {
+		t.Fatalf("Search(old) after Remove = %q, want [2]", got)
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+)
+
+type AuthorService interface {
+	ListAuthors() ([]*models.Author, error)
+	GetAuthor(id string) (*models.Author, error)
+	// CreateAuthor records the caller's auth.Principal as the owner.
+	CreateAuthor(ctx context.Context, author *models.Author) error
+	// UpdateAuthor replaces the name and bio. Only the owner or an admin
+	// may update an author.
+	UpdateAuthor(ctx context.Context, id string, author models.Author) (*models.Author, error)
+	// DeleteAuthor removes an author that no book credits, including books
+	// in the trash; otherwise it returns ErrAuthorHasBooks. Only the owner
+	// or an admin may delete an author.
+	DeleteAuthor(ctx context.Context, id string) error
+	// AuthorBooks returns the page of active books crediting the author.
+	AuthorBooks(id string, q models.BookQuery) (*models.BookPage, error)
+}
+
+type authorService struct {
+	authors repository.AuthorRepository
+	books   repository.BookRepository
+}
+
+func NewAuthorService(authors repository.AuthorRepository, books repository.BookRepository) AuthorService {
+	return &authorService{authors: authors, books: books}
+}
+
+// ListAuthors implements AuthorService.
+func (a *authorService) ListAuthors() ([]*models.Author, error) {
+	return a.authors.GetAll()
+}
+
+// GetAuthor implements AuthorService.
+func (a *authorService) GetAuthor(id string) (*models.Author, error) {
+	if err := ValidateAuthorID(id); err != nil {
+		return nil, err
+	}
+	return a.authors.GetByID(id)
+}
+
+// CreateAuthor implements AuthorService.
+func (a *authorService) CreateAuthor(ctx context.Context, author *models.Author) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return response.ErrUnauthorized
+	}
+	if err := ValidateAuthor(*author); err != nil {
+		return err
+	}
+	author.ID = uuid.New().String()
+	author.OwnerID = principal.ID
+	return a.authors.Create(author)
+}
+
+// UpdateAuthor implements AuthorService.
+func (a *authorService) UpdateAuthor(ctx context.Context, id string, author models.Author) (*models.Author, error) {
+	if err := ValidateAuthorID(id); err != nil {
+		return nil, err
+	}
+	if err := ValidateAuthor(author); err != nil {
+		return nil, err
+	}
+	return a.authors.UpdateFunc(id, func(stored *models.Author) error {
+		if err := authorize(ctx, stored.OwnerID); err != nil {
+			return err
+		}
+		stored.Name = author.Name
+		stored.Bio = author.Bio
+		return nil
+	})
+}
+
+// DeleteAuthor implements AuthorService.
+func (a *authorService) DeleteAuthor(ctx context.Context, id string) error {
+	if err := ValidateAuthorID(id); err != nil {
+		return err
+	}
+	// Book writes that credit the author wait for DeleteFunc, so none can
+	// add a credit between these checks and the delete.
+	return a.authors.DeleteFunc(id, func(author *models.Author) error {
+		if err := authorize(ctx, author.OwnerID); err != nil {
+			return err
+		}
+		_, total, err := a.books.Query(models.BookQuery{AuthorID: id, Limit: 1})
+		if err != nil && !errors.Is(err, response.ErrNoBooks) {
+			return err
+		}
+		if total > 0 {
+			return response.ErrAuthorHasBooks
+		}
+		trash, err := a.books.Trash()
+		if err != nil {
+			return err
+		}
+		for _, book := range trash {
+			if slices.Contains(book.AuthorIDs, id) {
+				return response.ErrAuthorHasBooks
+			}
+		}
+		return nil
+	})
+}
+
+// AuthorBooks implements AuthorService.
+func (a *authorService) AuthorBooks(id string, q models.BookQuery) (*models.BookPage, error) {
+	if err := ValidateAuthorID(id); err != nil {
+		return nil, err
+	}
+	if _, err := a.authors.GetByID(id); err != nil {
+		return nil, err
+	}
+	q = clampPage(q)
+	q.AuthorID = id
+	books, total, err := a.books.Query(q)
+	// An empty catalogue is just an author without books here.
+	if errors.Is(err, response.ErrNoBooks) {
+		books, err = []*models.Book{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.BookPage{Books: books, Total: total, Limit: q.Limit, Offset: q.Offset}, nil
+}
@@ -1,34 +1,208 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
 	"github.com/google/uuid"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/blob"
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/patch"
 	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/search"
 )
 
 type BookService interface {
 	GetAllBooks() ([]*models.Book, error)
 	GetBookByID(id string) (*models.Book, error)
-	CreateBook(book *models.Book) error
-	UpdateBook(id string, book models.Book) error
-	DeleteBook(id string) error
+	// CreateBook records the caller's auth.Principal as the book's owner.
+	CreateBook(ctx context.Context, book *models.Book) error
+	// UpdateBook replaces the stored book. A non-zero book.Version must
+	// match the stored version, otherwise ErrVersionMismatch is returned.
+	// Only the owner or an admin may update a book.
+	UpdateBook(ctx context.Context, id string, book models.Book) (*models.Book, error)
+	// PatchBook applies an RFC 7396 merge patch to the stored book and
+	// returns the result. The patched book is validated before it is saved.
+	// A non-zero version must match the stored version.
+	PatchBook(ctx context.Context, id string, mergePatch []byte, version int) (*models.Book, error)
+	// DeleteBook moves the book to the trash; a non-zero version must
+	// match. Only the owner or an admin may delete a book.
+	DeleteBook(ctx context.Context, id string, version int) error
+	// TrashedBooks lists the books deleted but not yet purged that the
+	// caller may restore: their own, or all of them for an admin.
+	TrashedBooks(ctx context.Context) ([]*models.Book, error)
+	// RestoreBook takes a book out of the trash. Only the owner or an admin
+	// may restore a book.
+	RestoreBook(ctx context.Context, id string) (*models.Book, error)
+	// History returns the change log of a book, oldest revision first. It
+	// stays available after the book is deleted or purged. Only the owner
+	// or an admin may read it.
+	History(ctx context.Context, id string) ([]models.Revision, error)
+	// RevertBook restores the content fields of the given revision as a new
+	// revision. A non-zero version must match the stored version. Only the
+	// owner or an admin may revert a book.
+	RevertBook(ctx context.Context, id string, revision, version int) (*models.Book, error)
+	// SetCover stores a JPEG, PNG or GIF image of at most MaxCoverSize bytes
+	// as the book's cover, along with a thumbnail, replacing any previous
+	// cover. Only the owner or an admin may change the cover.
+	SetCover(ctx context.Context, id string, image []byte) (*models.Book, error)
+	// Cover opens the book's cover image, or its ThumbnailContentType
+	// thumbnail, and returns it with the cover's metadata. The caller must
+	// close it.
+	Cover(ctx context.Context, id string, thumbnail bool) (io.ReadSeekCloser, *models.Cover, error)
 	SearchBooksByAuthor(author string) ([]*models.Book, error)
 	SearchBooksByTitle(title string) ([]*models.Book, error)
+	ListBooks(q models.BookQuery) (*models.BookPage, error)
+	// ExportBooks returns up to limit books whose IDs sort after the given
+	// one, in ID order, for walking the whole catalogue a page at a time;
+	// see BookRepository.ListAfter. It is never cached.
+	ExportBooks(after string, limit int) ([]*models.Book, error)
+	// SearchBooks runs a full-text search over title, author and
+	// description, returning at most limit books ranked by relevance.
+	SearchBooks(query string, limit int) ([]*models.Book, error)
 }
 
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
 type bookService struct {
-	repo repository.BookRepository
+	repo    repository.BookRepository
+	history repository.HistoryRepository
+	authors repository.AuthorRepository
+	covers  blob.Store
+	events  EventPublisher
+	index   *search.Index
+	// indexing serialises the writes to each book with their index
+	// updates; see reindex.
+	indexing [64]sync.Mutex
+}
+
+// reindex runs write, a change to the book with the given ID, and brings the
+// search index up to date with the book it returns: trashed books are
+// removed, others are indexed again. Changes to the same book are
+// serialised, so the index applies them in the order they were saved.
+func (b *bookService) reindex(id string, write func() (*models.Book, error)) (*models.Book, error) {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	mu := &b.indexing[h.Sum32()%uint32(len(b.indexing))]
+	mu.Lock()
+	defer mu.Unlock()
+
+	book, err := write()
+	if err != nil {
+		return nil, err
+	}
+	if book.DeletedAt != nil {
+		b.index.Remove(id)
+	} else {
+		b.index.Add(*book)
+	}
+	return book, nil
 }
 
 // CreateBook implements BookService.
-func (b *bookService) CreateBook(book *models.Book) error {
+func (b *bookService) CreateBook(ctx context.Context, book *models.Book) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return response.ErrUnauthorized
+	}
+	if err := ValidateBook(*book); err != nil {
+		return err
+	}
 	book.ID = uuid.New().String()
-	return b.repo.Create(book)
+	book.OwnerID = principal.ID
+	book.Cover = nil
+	var rev models.Revision
+	_, err := b.reindex(book.ID, func() (*models.Book, error) {
+		create := func() error { return b.logged(ctx, models.ActionCreated, 0, &rev).Create(book) }
+		return book, b.withAuthors(book.AuthorIDs, create)
+	})
+	if err != nil {
+		return err
+	}
+	b.publish(ctx, rev)
+	return nil
 }
 
 // DeleteBook implements BookService.
-func (b *bookService) DeleteBook(id string) error {
-	return b.repo.Delete(id)
+func (b *bookService) DeleteBook(ctx context.Context, id string, version int) error {
+	if err := ValidateID(id); err != nil {
+		return err
+	}
+	// Ownership never changes after creation, so checking it before the
+	// delete cannot race with an update.
+	book, err := b.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := authorize(ctx, book.OwnerID); err != nil {
+		return err
+	}
+	var rev models.Revision
+	_, err = b.reindex(id, func() (*models.Book, error) {
+		return b.logged(ctx, models.ActionDeleted, 0, &rev).Delete(id, version)
+	})
+	if err != nil {
+		return err
+	}
+	b.publish(ctx, rev)
+	return nil
+}
+
+// TrashedBooks implements BookService.
+func (b *bookService) TrashedBooks(ctx context.Context) ([]*models.Book, error) {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return nil, response.ErrUnauthorized
+	}
+	trash, err := b.repo.Trash()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(trash, func(book *models.Book) bool {
+		return !principal.CanModify(book.OwnerID)
+	}), nil
+}
+
+// RestoreBook implements BookService.
+func (b *bookService) RestoreBook(ctx context.Context, id string) (*models.Book, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+	trash, err := b.repo.Trash()
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(trash, func(book *models.Book) bool { return book.ID == id })
+	if i < 0 {
+		return nil, response.ErrBookNotFound
+	}
+	// As in DeleteBook, the owner cannot change between this check and the
+	// restore.
+	if err := authorize(ctx, trash[i].OwnerID); err != nil {
+		return nil, err
+	}
+	var rev models.Revision
+	book, err := b.reindex(id, func() (*models.Book, error) {
+		return b.logged(ctx, models.ActionRestored, 0, &rev).Restore(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.publish(ctx, rev)
+	return book, nil
 }
 
 // GetAllBooks implements BookService.
@@ -38,9 +212,64 @@ func (b *bookService) GetAllBooks() ([]*models.Book, error) {
 
 // GetBookByID implements BookService.
 func (b *bookService) GetBookByID(id string) (*models.Book, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
 	return b.repo.GetByID(id)
 }
 
+// ListBooks implements BookService.
+func (b *bookService) ListBooks(q models.BookQuery) (*models.BookPage, error) {
+	q = clampPage(q)
+	books, total, err := b.repo.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return &models.BookPage{Books: books, Total: total, Limit: q.Limit, Offset: q.Offset}, nil
+}
+
+// ExportBooks implements BookService.
+func (b *bookService) ExportBooks(after string, limit int) ([]*models.Book, error) {
+	return b.repo.ListAfter(after, limit)
+}
+
+// clampPage applies DefaultPageSize and MaxPageSize to q.
+func clampPage(q models.BookQuery) models.BookQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	q.Limit = min(q.Limit, MaxPageSize)
+	return q
+}
+
+// SearchBooks implements BookService.
+func (b *bookService) SearchBooks(query string, limit int) ([]*models.Book, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("%w: q is required", response.ErrInvalidQuery)
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	books := []*models.Book{}
+	for _, hit := range b.index.Search(query) {
+		if len(books) == limit {
+			break
+		}
+		book, err := b.repo.GetByID(hit.ID)
+		if errors.Is(err, response.ErrBookNotFound) {
+			// Deleted between the index lookup and now.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	return books, nil
+}
+
 // SearchBooksByAuthor implements BookService.
 func (b *bookService) SearchBooksByAuthor(author string) ([]*models.Book, error) {
 	return b.repo.SearchByAuthor(author)
@@ -52,10 +281,162 @@ func (b *bookService) SearchBooksByTitle(title string) ([]*models.Book, error) {
 }
 
 // UpdateBook implements BookService.
-func (b *bookService) UpdateBook(id string, book models.Book) error {
-	return b.repo.Update(id, book)
+func (b *bookService) UpdateBook(ctx context.Context, id string, book models.Book) (*models.Book, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+	if err := ValidateBook(book); err != nil {
+		return nil, err
+	}
+	return b.replace(ctx, id, book, models.ActionUpdated, 0)
+}
+
+// replace overwrites the content of the stored book with the already
+// validated book and records the change as action.
+func (b *bookService) replace(ctx context.Context, id string, book models.Book, action string, revertedFrom int) (*models.Book, error) {
+	var rev models.Revision
+	updated, err := b.reindex(id, func() (updated *models.Book, err error) {
+		err = b.withAuthors(book.AuthorIDs, func() error {
+			updated, err = b.logged(ctx, action, revertedFrom, &rev).UpdateFunc(id, func(stored *models.Book) error {
+				if err := authorize(ctx, stored.OwnerID); err != nil {
+					return err
+				}
+				if book.Version != 0 && stored.Version != book.Version {
+					return response.ErrVersionMismatch
+				}
+				version, owner, cover := stored.Version, stored.OwnerID, stored.Cover
+				*stored = book
+				stored.ID = id
+				stored.Version = version
+				stored.OwnerID = owner
+				stored.Cover = cover
+				return nil
+			})
+			return err
+		})
+		return updated, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.publish(ctx, rev)
+	return updated, nil
+}
+
+// PatchBook implements BookService.
+func (b *bookService) PatchBook(ctx context.Context, id string, mergePatch []byte, version int) (*models.Book, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+	// The authors are looked up, and kept from being deleted, around the
+	// update rather than inside it: a SQLite repository holds its only
+	// connection for the duration of UpdateFunc. Merge patches replace
+	// arrays wholesale, so the patch alone names them.
+	var authors struct {
+		IDs []string `json:"author_ids"`
+	}
+	// A patch this rejects, such as an array or a mistyped author_ids,
+	// could not have produced a valid book either.
+	if err := json.Unmarshal(mergePatch, &authors); err != nil {
+		return nil, fmt.Errorf("%w: %v", response.ErrInvalidPatch, err)
+	}
+
+	var rev models.Revision
+	book, err := b.reindex(id, func() (book *models.Book, err error) {
+		err = b.withAuthors(authors.IDs, func() error {
+			book, err = b.logged(ctx, models.ActionUpdated, 0, &rev).UpdateFunc(id, func(book *models.Book) error {
+				if err := authorize(ctx, book.OwnerID); err != nil {
+					return err
+				}
+				if version != 0 && book.Version != version {
+					return response.ErrVersionMismatch
+				}
+				doc, err := json.Marshal(book)
+				if err != nil {
+					return err
+				}
+				merged, err := patch.MergePatch(doc, mergePatch)
+				if err != nil {
+					return fmt.Errorf("%w: %v", response.ErrInvalidPatch, err)
+				}
+
+				var patched models.Book
+				dec := json.NewDecoder(bytes.NewReader(merged))
+				dec.DisallowUnknownFields()
+				if err := dec.Decode(&patched); err != nil {
+					return fmt.Errorf("%w: %v", response.ErrInvalidPatch, err)
+				}
+				// The ID is part of the resource address, the version and
+				// deletion time are owned by the repository, the owner is fixed
+				// at creation and the cover changes only by upload; none of them
+				// can be patched.
+				patched.ID = book.ID
+				patched.Version = book.Version
+				patched.OwnerID = book.OwnerID
+				patched.DeletedAt = book.DeletedAt
+				patched.Cover = book.Cover
+				if err := ValidateBook(patched); err != nil {
+					return err
+				}
+				*book = patched
+				return nil
+			})
+			return err
+		})
+		return book, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.publish(ctx, rev)
+	return book, nil
+}
+
+// withAuthors runs the book write fn under AuthorRepository.Reference, so
+// the authors in ids exist and cannot be deleted until it is done. An unknown
+// author is a validation error on author_ids; malformed IDs are left to
+// ValidateBook.
+func (b *bookService) withAuthors(ids []string, fn func() error) error {
+	valid := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err == nil {
+			valid = append(valid, id)
+		}
+	}
+	err := b.authors.Reference(valid, fn)
+	var verr *response.ValidationError
+	if errors.Is(err, response.ErrUnknownAuthor) && !errors.As(err, &verr) {
+		verr = &response.ValidationError{}
+		verr.Add("author_ids", err)
+		return verr
+	}
+	return err
+}
+
+// authorize checks that the caller in ctx may modify a book owned by ownerID.
+func authorize(ctx context.Context, ownerID string) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return response.ErrUnauthorized
+	}
+	if !principal.CanModify(ownerID) {
+		return response.ErrForbidden
+	}
+	return nil
 }
 
-func NewBookService(r repository.BookRepository) BookService {
-	return &bookService{repo: r}
+// NewBookService builds the search index from the books already in r, so a
+// persistent repository is searchable straight after a restart. Every change
+// is logged to r.History and published to e, if not nil; author IDs on books
+// must exist in a and cover images are kept in c.
+func NewBookService(r repository.BookRepository, a repository.AuthorRepository, c blob.Store, e EventPublisher) (BookService, error) {
+	index := search.NewIndex()
+	books, err := r.GetAll()
+	if err != nil && !errors.Is(err, response.ErrNoBooks) {
+		return nil, fmt.Errorf("build search index: %w", err)
+	}
+	for _, book := range books {
+		index.Add(*book)
+	}
+	return &bookService{repo: r, history: r.History(), authors: a, covers: c, events: e, index: index}, nil
 }
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/blob"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+)
+
+func newTestBookService(t *testing.T) BookService {
+	t.Helper()
+	svc, err := NewBookService(repository.NewBookRepository(), repository.NewAuthorRepository(), blob.NewMemoryStore(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+var alice = auth.WithPrincipal(context.Background(), auth.Principal{ID: "alice"})
+
+func TestSearchFollowsConcurrentUpdates(t *testing.T) {
+	svc := newTestBookService(t)
+	book := &models.Book{Title: "Draft", Author: "A"}
+	if err := svc.CreateBook(alice, book); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			update := models.Book{Title: fmt.Sprintf("title%02d", i), Author: "A"}
+			if _, err := svc.UpdateBook(alice, book.ID, update); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, err := svc.GetBookByID(book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 50 {
+		title := fmt.Sprintf("title%02d", i)
+		found, err := svc.SearchBooks(title, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := title == stored.Title; (len(found) == 1) != want {
+			t.Errorf("SearchBooks(%q) = %d books with %q stored", title, len(found), stored.Title)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+)
+
+// CacheConfig sizes the read cache in front of a BookService. A zero TTL or
+// Size disables caching.
+type CacheConfig struct {
+	TTL  time.Duration
+	Size int
+}
+
+// CacheStats counts read cache lookups since the cache was created.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Entries is the number of results currently cached.
+	Entries int
+}
+
+// CachingBookService serves the read methods of a BookService from an LRU
+// cache whose entries expire after a TTL. Every successful write through it
+// empties the cache; changes that bypass the service, such as the trash
+// purge, show up once the affected entries expire.
+type CachingBookService struct {
+	BookService
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	hits, misses, evictions atomic.Uint64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // most recently used at the front
+	// generation is bumped by every invalidation, so a read that raced a
+	// write does not cache what it fetched before the write.
+	generation uint64
+}
+
+type cacheEntry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+// NewCachingBookService wraps next in a read cache.
+func NewCachingBookService(next BookService, cfg CacheConfig) *CachingBookService {
+	return &CachingBookService{
+		BookService: next,
+		ttl:         cfg.TTL,
+		size:        cfg.Size,
+		now:         time.Now,
+		entries:     map[string]*list.Element{},
+		lru:         list.New(),
+	}
+}
+
+// Stats returns the hit, miss and eviction counts.
+func (c *CachingBookService) Stats() CacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   entries,
+	}
+}
+
+// cached returns the value stored under key, or calls load and stores its
+// result if it succeeds. Values are shared between callers, so clone must
+// return a copy the caller may modify.
+func cached[T any](c *CachingBookService, key string, load func() (T, error), clone func(T) T) (T, error) {
+	if v, ok := c.lookup(key); ok {
+		c.hits.Add(1)
+		return clone(v.(T)), nil
+	}
+	c.misses.Add(1)
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+	c.store(key, generation, clone(v))
+	return v, nil
+}
+
+func (c *CachingBookService) lookup(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *CachingBookService) store(key string, generation uint64, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	entry := &cacheEntry{key: key, value: value, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.evictions.Add(1)
+	}
+}
+
+// invalidate drops every cached result.
+func (c *CachingBookService) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	clear(c.entries)
+	c.lru.Init()
+}
+
+// GetAllBooks implements BookService.
+func (c *CachingBookService) GetAllBooks() ([]*models.Book, error) {
+	return cached(c, "all", c.BookService.GetAllBooks, cloneBooks)
+}
+
+// GetBookByID implements BookService.
+func (c *CachingBookService) GetBookByID(id string) (*models.Book, error) {
+	return cached(c, "id:"+id, func() (*models.Book, error) {
+		return c.BookService.GetBookByID(id)
+	}, cloneBook)
+}
+
+// TrashedBooks implements BookService.
+func (c *CachingBookService) TrashedBooks(ctx context.Context) ([]*models.Book, error) {
+	return cached(c, callerKey(ctx, "trash"), func() ([]*models.Book, error) {
+		return c.BookService.TrashedBooks(ctx)
+	}, cloneBooks)
+}
+
+// History implements BookService.
+func (c *CachingBookService) History(ctx context.Context, id string) ([]models.Revision, error) {
+	return cached(c, callerKey(ctx, "history:"+id), func() ([]models.Revision, error) {
+		return c.BookService.History(ctx, id)
+	}, cloneRevisions)
+}
+
+// SearchBooksByAuthor implements BookService.
+func (c *CachingBookService) SearchBooksByAuthor(author string) ([]*models.Book, error) {
+	return cached(c, "author:"+author, func() ([]*models.Book, error) {
+		return c.BookService.SearchBooksByAuthor(author)
+	}, cloneBooks)
+}
+
+// SearchBooksByTitle implements BookService.
+func (c *CachingBookService) SearchBooksByTitle(title string) ([]*models.Book, error) {
+	return cached(c, "title:"+title, func() ([]*models.Book, error) {
+		return c.BookService.SearchBooksByTitle(title)
+	}, cloneBooks)
+}
+
+// ListBooks implements BookService.
+func (c *CachingBookService) ListBooks(q models.BookQuery) (*models.BookPage, error) {
+	return cached(c, fmt.Sprintf("list:%+v", q), func() (*models.BookPage, error) {
+		return c.BookService.ListBooks(q)
+	}, func(page *models.BookPage) *models.BookPage {
+		clone := *page
+		clone.Books = cloneBooks(page.Books)
+		return &clone
+	})
+}
+
+// SearchBooks implements BookService.
+func (c *CachingBookService) SearchBooks(query string, limit int) ([]*models.Book, error) {
+	return cached(c, fmt.Sprintf("search:%d:%s", limit, query), func() ([]*models.Book, error) {
+		return c.BookService.SearchBooks(query, limit)
+	}, cloneBooks)
+}
+
+// CreateBook implements BookService.
+func (c *CachingBookService) CreateBook(ctx context.Context, book *models.Book) error {
+	err := c.BookService.CreateBook(ctx, book)
+	if err == nil {
+		c.invalidate()
+	}
+	return err
+}
+
+// UpdateBook implements BookService.
+func (c *CachingBookService) UpdateBook(ctx context.Context, id string, book models.Book) (*models.Book, error) {
+	return invalidating(c, func() (*models.Book, error) {
+		return c.BookService.UpdateBook(ctx, id, book)
+	})
+}
+
+// PatchBook implements BookService.
+func (c *CachingBookService) PatchBook(ctx context.Context, id string, mergePatch []byte, version int) (*models.Book, error) {
+	return invalidating(c, func() (*models.Book, error) {
+		return c.BookService.PatchBook(ctx, id, mergePatch, version)
+	})
+}
+
+// DeleteBook implements BookService.
+func (c *CachingBookService) DeleteBook(ctx context.Context, id string, version int) error {
+	err := c.BookService.DeleteBook(ctx, id, version)
+	if err == nil {
+		c.invalidate()
+	}
+	return err
+}
+
+// RestoreBook implements BookService.
+func (c *CachingBookService) RestoreBook(ctx context.Context, id string) (*models.Book, error) {
+	return invalidating(c, func() (*models.Book, error) {
+		return c.BookService.RestoreBook(ctx, id)
+	})
+}
+
+// SetCover implements BookService.
+func (c *CachingBookService) SetCover(ctx context.Context, id string, image []byte) (*models.Book, error) {
+	return invalidating(c, func() (*models.Book, error) {
+		return c.BookService.SetCover(ctx, id, image)
+	})
+}
+
+// RevertBook implements BookService.
+func (c *CachingBookService) RevertBook(ctx context.Context, id string, revision, version int) (*models.Book, error) {
+	return invalidating(c, func() (*models.Book, error) {
+		return c.BookService.RevertBook(ctx, id, revision, version)
+	})
+}
+
+// callerKey scopes key to the caller, for reads whose result depends on who
+// is asking.
+func callerKey(ctx context.Context, key string) string {
+	principal, _ := auth.PrincipalFrom(ctx)
+	return fmt.Sprintf("%s:%s@%s", key, principal.ID, principal.Role)
+}
+
+func invalidating(c *CachingBookService, write func() (*models.Book, error)) (*models.Book, error) {
+	book, err := write()
+	if err == nil {
+		c.invalidate()
+	}
+	return book, err
+}
+
+func cloneBook(book *models.Book) *models.Book {
+	if book == nil {
+		return nil
+	}
+	clone := *book
+	clone.AuthorIDs = slices.Clone(book.AuthorIDs)
+	if book.DeletedAt != nil {
+		deletedAt := *book.DeletedAt
+		clone.DeletedAt = &deletedAt
+	}
+	if book.Cover != nil {
+		cover := *book.Cover
+		clone.Cover = &cover
+	}
+	return &clone
+}
+
+func cloneBooks(books []*models.Book) []*models.Book {
+	if books == nil {
+		return nil
+	}
+	clones := make([]*models.Book, len(books))
+	for i, book := range books {
+		clones[i] = cloneBook(book)
+	}
+	return clones
+}
+
+func cloneRevisions(revisions []models.Revision) []models.Revision {
+	if revisions == nil {
+		return nil
+	}
+	clones := make([]models.Revision, len(revisions))
+	for i, rev := range revisions {
+		rev.Changes = slices.Clone(rev.Changes)
+		for j, c := range rev.Changes {
+			rev.Changes[j].Before = slices.Clone(c.Before)
+			rev.Changes[j].After = slices.Clone(c.After)
+		}
+		rev.Book = *cloneBook(&rev.Book)
+		clones[i] = rev
+	}
+	return clones
+}
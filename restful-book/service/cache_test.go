@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+func TestCachingBookService(t *testing.T) {
+	c := NewCachingBookService(newTestBookService(t), CacheConfig{TTL: time.Minute, Size: 2})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	book := &models.Book{Title: "T", Author: "A"}
+	if err := c.CreateBook(alice, book); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := c.GetBookByID(book.ID)
+	got.Title = "mutated"
+	if got, _ = c.GetBookByID(book.ID); got.Title != "T" {
+		t.Fatalf("cached book shared with a caller: title %q", got.Title)
+	}
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Fatalf("after two reads: %+v, want 1 hit and 1 miss", s)
+	}
+
+	c.ListBooks(models.BookQuery{Limit: 5})
+	c.ListBooks(models.BookQuery{Limit: 6})
+	if s := c.Stats(); s.Evictions != 1 || s.Entries != 2 {
+		t.Fatalf("over capacity: %+v, want 1 eviction and 2 entries", s)
+	}
+	// Exports walk the whole catalogue and would only evict the rest.
+	if books, err := c.ExportBooks("", 0); err != nil || len(books) != 1 {
+		t.Fatalf("ExportBooks = %v, %v", books, err)
+	}
+	if s := c.Stats(); s.Evictions != 1 || s.Misses != 3 {
+		t.Fatalf("after an export: %+v, want it uncached", s)
+	}
+
+	if _, err := c.UpdateBook(alice, book.ID, models.Book{Title: "T2", Author: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Stats(); s.Entries != 0 {
+		t.Fatalf("after a write: %d entries, want 0", s.Entries)
+	}
+	if got, _ = c.GetBookByID(book.ID); got.Title != "T2" {
+		t.Fatalf("stale read after update: title %q", got.Title)
+	}
+
+	misses := c.Stats().Misses
+	now = now.Add(time.Minute)
+	c.GetBookByID(book.ID)
+	if s := c.Stats(); s.Misses != misses+1 {
+		t.Fatalf("expired entry served: %+v", s)
+	}
+
+	history, _ := c.History(alice, book.ID)
+	change := history[1].Changes[0]
+	field, after := change.Field, string(change.After)
+	history[1].Changes[0].Field = "mutated"
+	change.After[0] = 'x'
+	if history, _ = c.History(alice, book.ID); history[1].Changes[0].Field != field || string(history[1].Changes[0].After) != after {
+		t.Fatalf("cached history shared with a caller: %+v", history[1].Changes[0])
+	}
+
+	// What alice may read is cached for alice alone.
+	bob := auth.WithPrincipal(context.Background(), auth.Principal{ID: "bob"})
+	if _, err := c.History(bob, book.ID); !errors.Is(err, response.ErrForbidden) {
+		t.Fatalf("History as bob after alice = %v, want ErrForbidden", err)
+	}
+	if err := c.DeleteBook(alice, book.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if trash, err := c.TrashedBooks(alice); err != nil || len(trash) != 1 {
+		t.Fatalf("TrashedBooks as alice = %v, %v", trash, err)
+	}
+	if trash, err := c.TrashedBooks(bob); err != nil || len(trash) != 0 {
+		t.Fatalf("TrashedBooks as bob after alice = %v, %v", trash, err)
+	}
+}
+
+func TestCachingBookServiceConcurrency(t *testing.T) {
+	c := NewCachingBookService(newTestBookService(t), CacheConfig{TTL: time.Minute, Size: 4})
+	book := &models.Book{Title: "T", Author: "A"}
+	if err := c.CreateBook(alice, book); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				c.ListBooks(models.BookQuery{Limit: j % 3})
+				c.GetBookByID(book.ID)
+				c.PatchBook(alice, book.ID, []byte(`{"description":"x"}`), 0)
+				c.History(alice, book.ID)
+			}
+		}()
+	}
+	wg.Wait()
+}
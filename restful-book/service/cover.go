@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/blob"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+const (
+	// MaxCoverSize is the largest cover image accepted, in bytes.
+	MaxCoverSize = 5 << 20
+	// ThumbnailSize bounds the width and height of cover thumbnails.
+	ThumbnailSize = 256
+	// ThumbnailContentType is the format thumbnails are stored in.
+	ThumbnailContentType = "image/png"
+	// maxCoverPixels stops a small, highly compressed file from decoding
+	// into an enormous bitmap.
+	maxCoverPixels = 25_000_000
+)
+
+// coverTypes are the accepted cover formats, as sniffed from the content.
+var coverTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// coverKey names the blob of a cover image, or of its thumbnail. Keys
+// include the content hash so a new upload never overwrites the image a
+// concurrent reader is serving.
+func coverKey(bookID, sum string, thumbnail bool) string {
+	key := "covers/" + bookID + "/" + sum
+	if thumbnail {
+		key += "-thumb"
+	}
+	return key
+}
+
+// SetCover implements BookService.
+func (b *bookService) SetCover(ctx context.Context, id string, data []byte) (*models.Book, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+	if len(data) > MaxCoverSize {
+		return nil, fmt.Errorf("%w: covers are limited to %d bytes", response.ErrCoverTooLarge, MaxCoverSize)
+	}
+	contentType := http.DetectContentType(data)
+	if !coverTypes[contentType] {
+		return nil, fmt.Errorf("%w: covers must be JPEG, PNG or GIF images, got %s", response.ErrUnsupportedMedia, contentType)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", response.ErrInvalidImage, err)
+	}
+	if config.Width*config.Height > maxCoverPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", response.ErrCoverTooLarge, config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", response.ErrInvalidImage, err)
+	}
+	var thumb bytes.Buffer
+	if err := png.Encode(&thumb, thumbnail(img, ThumbnailSize)); err != nil {
+		return nil, fmt.Errorf("encode thumbnail: %w", err)
+	}
+
+	// Check ownership before anything is stored; UpdateFunc checks again
+	// in case the book changed hands meanwhile.
+	stored, err := b.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, stored.OwnerID); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+	cover := &models.Cover{
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       config.Width,
+		Height:      config.Height,
+		SHA256:      hex.EncodeToString(hash[:]),
+		UpdatedAt:   time.Now().UTC().Round(0),
+	}
+	if err := b.covers.Put(ctx, coverKey(id, cover.SHA256, false), bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("store cover: %w", err)
+	}
+	if err := b.covers.Put(ctx, coverKey(id, cover.SHA256, true), &thumb); err != nil {
+		return nil, fmt.Errorf("store thumbnail: %w", err)
+	}
+
+	var before models.Book
+	var rev models.Revision
+	updated, err := b.logged(ctx, models.ActionUpdated, 0, &rev).UpdateFunc(id, func(book *models.Book) error {
+		if err := authorize(ctx, book.OwnerID); err != nil {
+			return err
+		}
+		before = *book
+		book.Cover = cover
+		return nil
+	})
+	if err != nil {
+		if stored.Cover == nil || stored.Cover.SHA256 != cover.SHA256 {
+			deleteCover(ctx, b.covers, id, cover.SHA256)
+		}
+		return nil, err
+	}
+	if before.Cover != nil && before.Cover.SHA256 != cover.SHA256 {
+		deleteCover(ctx, b.covers, id, before.Cover.SHA256)
+	}
+	b.publish(ctx, rev)
+	return updated, nil
+}
+
+// deleteCover removes a cover image and its thumbnail. Failures only leave
+// unreferenced blobs behind, so they are logged rather than returned.
+func deleteCover(ctx context.Context, covers blob.Store, id, sum string) {
+	for _, thumbnail := range []bool{false, true} {
+		if err := covers.Delete(ctx, coverKey(id, sum, thumbnail)); err != nil {
+			slog.ErrorContext(ctx, "failed to delete cover", "book_id", id, "sha256", sum, "err", err)
+		}
+	}
+}
+
+// Cover implements BookService.
+func (b *bookService) Cover(ctx context.Context, id string, thumbnail bool) (io.ReadSeekCloser, *models.Cover, error) {
+	book, err := b.GetBookByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if book.Cover == nil {
+		return nil, nil, response.ErrCoverNotFound
+	}
+	file, err := b.covers.Open(ctx, coverKey(id, book.Cover.SHA256, thumbnail))
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, nil, response.ErrCoverNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, book.Cover, nil
+}
+
+// thumbnail scales src down to fit within size×size, averaging the source
+// pixels each thumbnail pixel covers. Smaller images are only copied.
+func thumbnail(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	dst := image.NewRGBA64(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0 := bounds.Min.Y + y*h/th
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*h/th)
+		for x := 0; x < tw; x++ {
+			x0 := bounds.Min.X + x*w/tw
+			x1 := max(x0+1, bounds.Min.X+(x+1)*w/tw)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+)
+
+// EventPublisher is told about every book change once it has been saved.
+// Publish must not block on delivery.
+type EventPublisher interface {
+	Publish(ctx context.Context, event models.Event)
+}
+
+// Publishers passes every event to each of its members in turn.
+type Publishers []EventPublisher
+
+// Publish implements EventPublisher.
+func (ps Publishers) Publish(ctx context.Context, event models.Event) {
+	for _, p := range ps {
+		p.Publish(ctx, event)
+	}
+}
+
+// eventTypes maps the history actions to the events they raise.
+var eventTypes = map[string]string{
+	models.ActionCreated:  models.EventBookCreated,
+	models.ActionUpdated:  models.EventBookUpdated,
+	models.ActionDeleted:  models.EventBookDeleted,
+	models.ActionRestored: models.EventBookUpdated,
+	models.ActionReverted: models.EventBookUpdated,
+}
+
+// publish tells the event publisher, if there is one, about the saved
+// change rev records.
+func (b *bookService) publish(ctx context.Context, rev models.Revision) {
+	if b.events == nil {
+		return
+	}
+	b.events.Publish(ctx, models.Event{
+		ID:        uuid.New().String(),
+		Type:      eventTypes[rev.Action],
+		Timestamp: rev.Timestamp,
+		Book:      rev.Book,
+	})
+}
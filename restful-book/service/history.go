@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/requestid"
+)
+
+// History implements BookService.
+func (b *bookService) History(ctx context.Context, id string) ([]models.Revision, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+	revisions, err := b.history.List(id)
+	if err != nil {
+		return nil, err
+	}
+	// The latest revision still names the owner once the book is purged.
+	if err := authorize(ctx, revisions[len(revisions)-1].Book.OwnerID); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// RevertBook implements BookService.
+func (b *bookService) RevertBook(ctx context.Context, id string, revision, version int) (*models.Book, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+	target, err := b.history.Get(id, revision)
+	if err != nil {
+		return nil, err
+	}
+	// Only the content is reverted: the identity, owner, version and trash
+	// state belong to the live book.
+	content := target.Book
+	content.Version = version
+	content.DeletedAt = nil
+	if err := ValidateBook(content); err != nil {
+		return nil, err
+	}
+	// An author credited back then may have been deleted since, which
+	// replace reports.
+	return b.replace(ctx, id, content, models.ActionReverted, revision)
+}
+
+// logged returns the book repository to make a change of the given action
+// through. It appends the revision of the change in the same step as the
+// change and stores it in rev, for the event published once it is saved.
+func (b *bookService) logged(ctx context.Context, action string, revertedFrom int, rev *models.Revision) repository.BookRepository {
+	principal, _ := auth.PrincipalFrom(ctx)
+	requestID := requestid.From(ctx)
+	return b.repo.Logged(func(before, after models.Book) models.Revision {
+		*rev = models.Revision{
+			BookID:       after.ID,
+			Revision:     after.Version,
+			Action:       action,
+			Actor:        principal.ID,
+			RequestID:    requestID,
+			Timestamp:    time.Now().UTC().Round(0),
+			RevertedFrom: revertedFrom,
+			Changes:      models.DiffBooks(before, after),
+			Book:         after,
+		}
+		return *rev
+	})
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/blob"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+)
+
+// PurgeInterval is how often PurgeTrash looks for expired books.
+const PurgeInterval = time.Hour
+
+// PurgeTrash permanently removes books that have been in the trash for longer
+// than retention, along with their covers in covers, once straight away and
+// then every interval, until ctx is done. Trashed books are not in the search
+// index, so purging bypasses the BookService.
+func PurgeTrash(ctx context.Context, repo repository.BookRepository, covers blob.Store, retention, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := repo.Purge(time.Now().Add(-retention))
+		switch {
+		case err != nil:
+			logger.Error("purging trash failed", "err", err)
+		case len(purged) > 0:
+			logger.Info("purged trashed books", "count", len(purged), "retention", retention)
+		}
+		for _, book := range purged {
+			if book.Cover != nil {
+				deleteCover(ctx, covers, book.ID, book.Cover.SHA256)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
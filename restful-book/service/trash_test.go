@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/blob"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+)
+
+func TestPurgeTrashDeletesCovers(t *testing.T) {
+	ctx := context.Background()
+	repo, covers := repository.NewBookRepository(), blob.NewMemoryStore()
+	for _, book := range []*models.Book{
+		{ID: "trashed", Title: "T", Author: "A", Cover: &models.Cover{SHA256: "1"}},
+		{ID: "kept", Title: "K", Author: "A", Cover: &models.Cover{SHA256: "2"}},
+	} {
+		if err := repo.Create(book); err != nil {
+			t.Fatal(err)
+		}
+		for _, thumbnail := range []bool{false, true} {
+			if err := covers.Put(ctx, coverKey(book.ID, book.Cover.SHA256, thumbnail), strings.NewReader("image")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := repo.Delete("trashed", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A done context makes PurgeTrash return after its first pass.
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	PurgeTrash(done, repo, covers, 0, time.Hour, slog.New(slog.DiscardHandler))
+
+	for _, tc := range []struct {
+		id, sum string
+		want    error
+	}{
+		{"trashed", "1", blob.ErrNotFound},
+		{"kept", "2", nil},
+	} {
+		for _, thumbnail := range []bool{false, true} {
+			file, err := covers.Open(ctx, coverKey(tc.id, tc.sum, thumbnail))
+			if !errors.Is(err, tc.want) {
+				t.Errorf("Open(%s cover, thumbnail %v) = %v, want %v", tc.id, thumbnail, err, tc.want)
+			}
+			if err == nil {
+				file.Close()
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+)
+
+const (
+	maxTitleLength       = 255
+	maxAuthorLength      = 255
+	maxDescriptionLength = 5000
+	maxAuthorsPerBook    = 20
+	maxBioLength         = 5000
+	maxURLLength         = 2048
+)
+
+// ValidateBook checks the client-supplied fields of book and returns a
+// *response.ValidationError listing every field that failed, or nil.
+func ValidateBook(book models.Book) error {
+	var verr response.ValidationError
+
+	switch {
+	case strings.TrimSpace(book.Title) == "":
+		verr.Add("title", response.ErrEmptyBookTitle)
+	case utf8.RuneCountInString(book.Title) > maxTitleLength:
+		verr.Add("title", response.ErrFieldTooLong)
+	}
+
+	// The author text may be left out when the authors are linked by ID.
+	switch {
+	case strings.TrimSpace(book.Author) == "" && len(book.AuthorIDs) == 0:
+		verr.Add("author", response.ErrEmptyBookAuthor)
+	case utf8.RuneCountInString(book.Author) > maxAuthorLength:
+		verr.Add("author", response.ErrFieldTooLong)
+	}
+
+	// Zero means the year is unknown; anything else must be plausible.
+	if book.PublishedYear != 0 && (book.PublishedYear < 1 || book.PublishedYear > time.Now().Year()+1) {
+		verr.Add("published_year", response.ErrInvalidYear)
+	}
+
+	if book.ISBN != "" && !ValidISBN(book.ISBN) {
+		verr.Add("isbn", response.ErrInvalidISBN)
+	}
+
+	if len(book.AuthorIDs) > maxAuthorsPerBook {
+		verr.Add("author_ids", response.ErrTooManyAuthors)
+	}
+	seen := map[string]bool{}
+	for _, id := range book.AuthorIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			verr.Add("author_ids", response.ErrInvalidAuthorID)
+			break
+		}
+		if seen[id] {
+			verr.Add("author_ids", response.ErrDuplicateAuthor)
+			break
+		}
+		seen[id] = true
+	}
+
+	if utf8.RuneCountInString(book.Description) > maxDescriptionLength {
+		verr.Add("description", response.ErrFieldTooLong)
+	}
+
+	return verr.Err()
+}
+
+// ValidateAuthor checks the client-supplied fields of author like
+// ValidateBook.
+func ValidateAuthor(author models.Author) error {
+	var verr response.ValidationError
+
+	switch {
+	case strings.TrimSpace(author.Name) == "":
+		verr.Add("name", response.ErrEmptyAuthorName)
+	case utf8.RuneCountInString(author.Name) > maxAuthorLength:
+		verr.Add("name", response.ErrFieldTooLong)
+	}
+
+	if utf8.RuneCountInString(author.Bio) > maxBioLength {
+		verr.Add("bio", response.ErrFieldTooLong)
+	}
+
+	return verr.Err()
+}
+
+// ValidateWebhook checks the client-supplied fields of webhook like
+// ValidateBook.
+func ValidateWebhook(webhook models.Webhook) error {
+	var verr response.ValidationError
+
+	if u, err := url.Parse(webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		verr.Add("url", response.ErrInvalidURL)
+	} else if len(webhook.URL) > maxURLLength {
+		verr.Add("url", response.ErrFieldTooLong)
+	}
+
+	seen := map[string]bool{}
+	for _, event := range webhook.Events {
+		if !slices.Contains(models.EventTypes, event) {
+			verr.Add("events", fmt.Errorf("%w: %s", response.ErrUnknownEvent, event))
+			break
+		}
+		if seen[event] {
+			verr.Add("events", response.ErrDuplicateEvent)
+			break
+		}
+		seen[event] = true
+	}
+
+	return verr.Err()
+}
+
+// ValidateID rejects IDs that are not UUIDs, the only shape CreateBook issues.
+func ValidateID(id string) error {
+	return validateUUID(id, response.ErrInvalidBookID)
+}
+
+// ValidateAuthorID is ValidateID for author IDs.
+func ValidateAuthorID(id string) error {
+	return validateUUID(id, response.ErrInvalidAuthorID)
+}
+
+// ValidateWebhookID is ValidateID for webhook IDs.
+func ValidateWebhookID(id string) error {
+	return validateUUID(id, response.ErrInvalidWebhookID)
+}
+
+func validateUUID(id string, invalid error) error {
+	if _, err := uuid.Parse(id); err != nil {
+		var verr response.ValidationError
+		verr.Add("id", invalid)
+		return &verr
+	}
+	return nil
+}
+
+// ValidISBN reports whether isbn is an ISBN-10 or ISBN-13 with a correct
+// check digit. Hyphens and spaces are ignored.
+func ValidISBN(isbn string) bool {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(isbn)
+	switch len(digits) {
+	case 10:
+		sum := 0
+		for i, r := range digits {
+			var d int
+			switch {
+			case r >= '0' && r <= '9':
+				d = int(r - '0')
+			case (r == 'X' || r == 'x') && i == 9:
+				d = 10
+			default:
+				return false
+			}
+			sum += (10 - i) * d
+		}
+		return sum%11 == 0
+	case 13:
+		sum := 0
+		for i, r := range digits {
+			if r < '0' || r > '9' {
+				return false
+			}
+			d := int(r - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		return sum%10 == 0
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/auth"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+)
+
+type WebhookService interface {
+	// ListWebhooks returns the caller's webhooks, or every webhook for an
+	// admin, without their secrets.
+	ListWebhooks(ctx context.Context) ([]*models.Webhook, error)
+	// GetWebhook returns a webhook without its secret. Only the owner or an
+	// admin may see a webhook.
+	GetWebhook(ctx context.Context, id string) (*models.Webhook, error)
+	// CreateWebhook records the caller's auth.Principal as the owner and
+	// issues the secret deliveries are signed with, which is returned in
+	// webhook.Secret.
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	// DeleteWebhook stops deliveries to the webhook and discards its dead
+	// letters. Only the owner or an admin may delete a webhook.
+	DeleteWebhook(ctx context.Context, id string) error
+	// DeadLetters returns the events the webhook could not be sent, oldest
+	// first. Only the owner or an admin may see them.
+	DeadLetters(ctx context.Context, id string) ([]models.DeadLetter, error)
+}
+
+type webhookService struct {
+	webhooks repository.WebhookRepository
+}
+
+func NewWebhookService(webhooks repository.WebhookRepository) WebhookService {
+	return &webhookService{webhooks: webhooks}
+}
+
+// ListWebhooks implements WebhookService.
+func (s *webhookService) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return nil, response.ErrUnauthorized
+	}
+	all, err := s.webhooks.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	webhooks := []*models.Webhook{}
+	for _, webhook := range all {
+		if principal.CanModify(webhook.OwnerID) {
+			webhook.Secret = ""
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+// GetWebhook implements WebhookService.
+func (s *webhookService) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	webhook, err := s.owned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+// CreateWebhook implements WebhookService.
+func (s *webhookService) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return response.ErrUnauthorized
+	}
+	if err := ValidateWebhook(*webhook); err != nil {
+		return err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	webhook.ID = uuid.New().String()
+	webhook.Secret = hex.EncodeToString(secret)
+	webhook.OwnerID = principal.ID
+	webhook.CreatedAt = time.Now().UTC().Round(0)
+	return s.webhooks.Create(webhook)
+}
+
+// DeleteWebhook implements WebhookService.
+func (s *webhookService) DeleteWebhook(ctx context.Context, id string) error {
+	if _, err := s.owned(ctx, id); err != nil {
+		return err
+	}
+	return s.webhooks.Delete(id)
+}
+
+// DeadLetters implements WebhookService.
+func (s *webhookService) DeadLetters(ctx context.Context, id string) ([]models.DeadLetter, error) {
+	if _, err := s.owned(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.webhooks.DeadLetters(id)
+}
+
+// owned returns the webhook if the caller in ctx may manage it.
+func (s *webhookService) owned(ctx context.Context, id string) (*models.Webhook, error) {
+	if err := ValidateWebhookID(id); err != nil {
+		return nil, err
+	}
+	webhook, err := s.webhooks.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, webhook.OwnerID); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
@@ -0,0 +1,178 @@
+// Package stream fans book events out to live subscribers, such as the
+// Server-Sent Events endpoint, and keeps the most recent ones so a
+// subscriber that reconnects can pick up where it left off.
+package stream
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+)
+
+// DefaultBufferSize is how many events a Hub keeps for resuming
+// subscribers when NewHub is given no size.
+const DefaultBufferSize = 1000
+
+// subscriberQueue is how many events a subscriber may fall behind before
+// the Hub drops it.
+const subscriberQueue = 64
+
+// Message is an event numbered in the order the Hub published it. Sequence
+// numbers start at 1 and restart with the process, so ID also carries the
+// Hub's epoch, which is new for every Hub: "<epoch>-<seq>".
+type Message struct {
+	ID    string
+	Seq   uint64
+	Event models.Event
+}
+
+// ParseID splits a Message ID into its epoch and sequence number.
+func ParseID(id string) (epoch string, seq uint64, ok bool) {
+	epoch, rawSeq, found := strings.Cut(id, "-")
+	if !found || epoch == "" {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(rawSeq, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return epoch, seq, true
+}
+
+// Hub numbers published events, keeps the last few in a ring buffer and
+// passes every event on to the current subscribers.
+type Hub struct {
+	epoch       string
+	mu          sync.Mutex
+	buffer      []Message // ring of the latest len(buffer) messages
+	next        int       // where the next message goes in buffer
+	seq         uint64    // sequence number of the latest message
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewHub(size int) *Hub {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	epoch := make([]byte, 8)
+	rand.Read(epoch)
+	return &Hub{
+		epoch:       hex.EncodeToString(epoch),
+		buffer:      make([]Message, 0, size),
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription receives the events published after it was made. C is
+// closed when the subscriber falls too far behind, or the Hub is closed;
+// either way the subscriber should reconnect, resuming from the last Seq it
+// saw.
+type Subscription struct {
+	C   <-chan Message
+	c   chan Message
+	hub *Hub
+}
+
+// Publish implements service.EventPublisher. It never blocks: a subscriber
+// whose queue is full is dropped instead.
+func (h *Hub) Publish(_ context.Context, event models.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.seq++
+	msg := Message{ID: h.epoch + "-" + strconv.FormatUint(h.seq, 10), Seq: h.seq, Event: event}
+	if len(h.buffer) < cap(h.buffer) {
+		h.buffer = append(h.buffer, msg)
+	} else {
+		h.buffer[h.next] = msg
+	}
+	h.next = (h.next + 1) % cap(h.buffer)
+
+	for sub := range h.subscribers {
+		select {
+		case sub.c <- msg:
+		default:
+			h.drop(sub)
+		}
+	}
+}
+
+// Subscribe starts a subscription. Given the ID of the last message the
+// subscriber saw, it also returns the buffered messages after it, oldest
+// first; complete is false if some of them have already left the buffer, or
+// lastID is not from this Hub, e.g. it is from before a restart, in which
+// case the subscriber has missed events and should reload. An empty lastID
+// subscribes to new messages only.
+func (h *Hub) Subscribe(lastID string) (sub *Subscription, replay []Message, complete bool) {
+	c := make(chan Message, subscriberQueue)
+	sub = &Subscription{C: c, c: c, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(c)
+		return sub, nil, true
+	}
+	h.subscribers[sub] = struct{}{}
+	if lastID == "" {
+		return sub, nil, true
+	}
+
+	epoch, lastSeq, ok := ParseID(lastID)
+	if !ok || epoch != h.epoch || lastSeq > h.seq {
+		// Everything buffered is news to the subscriber, and it may have
+		// missed more.
+		lastSeq, complete = 0, false
+	} else {
+		oldest := h.seq - uint64(len(h.buffer)) + 1
+		complete = lastSeq+1 >= oldest
+	}
+	for i := range h.buffer {
+		msg := h.buffer[(h.next+i)%len(h.buffer)]
+		if msg.Seq > lastSeq {
+			replay = append(replay, msg)
+		}
+	}
+	return sub, replay, complete
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
+
+// Subscribers returns the number of open subscriptions.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+// Close ends every subscription and ignores later events, so that streaming
+// handlers return and the server can shut down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		h.drop(sub)
+	}
+}
+
+// drop removes sub and closes its channel; h.mu must be held.
+func (h *Hub) drop(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.c)
+	}
+}
@@ -0,0 +1,108 @@
+package stream
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+)
+
+func publish(h *Hub, n int) {
+	for range n {
+		h.Publish(context.Background(), models.Event{Type: models.EventBookUpdated})
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	h := NewHub(3)
+	slow, _, _ := h.Subscribe("")
+	publish(h, subscriberQueue+1)
+
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if n != subscriberQueue {
+		t.Fatalf("slow subscriber got %d events, want %d", n, subscriberQueue)
+	}
+	if got := h.Subscribers(); got != 0 {
+		t.Fatalf("Subscribers() = %d after drop, want 0", got)
+	}
+}
+
+func TestHubResume(t *testing.T) {
+	h := NewHub(3)
+	publish(h, 5)
+	id := func(seq uint64) string { return h.epoch + "-" + strconv.FormatUint(seq, 10) }
+
+	tests := []struct {
+		name         string
+		lastID       string
+		wantFirst    uint64
+		wantReplay   int
+		wantComplete bool
+	}{
+		{"InBuffer", id(3), 4, 2, true},
+		{"Current", id(5), 0, 0, true},
+		{"Evicted", id(1), 3, 3, false},
+		{"FromTheFuture", id(9), 3, 3, false},
+		// A restarted process numbers its events from 1 again, so an ID
+		// from the previous one must not be taken for one of the new ones.
+		{"OtherEpoch", "0123456789abcdef-3", 3, 3, false},
+		{"Malformed", "3", 3, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, complete := h.Subscribe(tt.lastID)
+			defer sub.Close()
+			if len(replay) != tt.wantReplay || complete != tt.wantComplete {
+				t.Fatalf("Subscribe(%q) replayed %d, complete=%v; want %d, %v",
+					tt.lastID, len(replay), complete, tt.wantReplay, tt.wantComplete)
+			}
+			if len(replay) > 0 && replay[0].Seq != tt.wantFirst {
+				t.Fatalf("first replayed Seq = %d, want %d", replay[0].Seq, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestMessageID(t *testing.T) {
+	h := NewHub(1)
+	sub, _, _ := h.Subscribe("")
+	publish(h, 1)
+	msg := <-sub.C
+	epoch, seq, ok := ParseID(msg.ID)
+	if !ok || epoch != h.epoch || seq != msg.Seq {
+		t.Fatalf("ParseID(%q) = %q, %d, %v", msg.ID, epoch, seq, ok)
+	}
+	if other := NewHub(1); other.epoch == h.epoch {
+		t.Fatal("two hubs share an epoch")
+	}
+	for _, id := range []string{"", "7", "-7", "abc-", "abc-x"} {
+		if _, _, ok := ParseID(id); ok {
+			t.Errorf("ParseID(%q) accepted a malformed ID", id)
+		}
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	h := NewHub(0)
+	sub, _, _ := h.Subscribe("")
+	other, _, _ := h.Subscribe("")
+	other.Close()
+	other.Close()
+
+	h.Close()
+	if _, ok := <-sub.C; ok {
+		t.Fatal("subscription still open after Close")
+	}
+	if got := h.Subscribers(); got != 0 {
+		t.Fatalf("Subscribers() = %d, want 0", got)
+	}
+	publish(h, 1)
+	late, _, _ := h.Subscribe("")
+	if _, ok := <-late.C; ok {
+		t.Fatal("Subscribe after Close returned an open subscription")
+	}
+}
@@ -0,0 +1,277 @@
+// Package webhook delivers book events to subscriber URLs as signed POST
+// requests, retrying failures with exponential backoff and keeping the
+// events that never get through as dead letters.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/response"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+)
+
+// Headers sent with every delivery besides SignatureHeader.
+const (
+	EventHeader   = "X-Webhook-Event"
+	EventIDHeader = "X-Webhook-Event-ID"
+)
+
+// Config tunes delivery. Zero fields take their DefaultConfig value.
+type Config struct {
+	// Attempts is how many times an event is sent before it becomes a
+	// dead letter.
+	Attempts int
+	// Backoff is the wait before the first retry. It doubles after every
+	// further failure up to MaxBackoff, with up to 20% jitter.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// Workers is how many deliveries, retries included, run at once.
+	Workers int
+	// QueueSize is how many deliveries may wait for a worker. Events that
+	// find the queue full are dead-lettered without being sent.
+	QueueSize int
+	// AllowPrivateNetworks lets webhooks reach loopback, link-local and
+	// private addresses. It is off by default so that registering a webhook
+	// cannot be used to probe the server's own network.
+	AllowPrivateNetworks bool
+	// Client sends the requests. It defaults to a client that enforces
+	// AllowPrivateNetworks at dial time, which a client passed here has to
+	// do itself. Redirects are never followed, whichever client is used.
+	Client *http.Client
+}
+
+// DefaultConfig spreads eight attempts over about three minutes.
+var DefaultConfig = Config{
+	Attempts:   8,
+	Backoff:    2 * time.Second,
+	MaxBackoff: time.Minute,
+	Timeout:    10 * time.Second,
+	Workers:    16,
+	QueueSize:  1024,
+}
+
+// Dispatcher sends events to the webhooks subscribed to them. Deliveries
+// wait in a bounded queue for one of a fixed number of workers, so a webhook
+// may receive events out of order.
+type Dispatcher struct {
+	webhooks repository.WebhookRepository
+	cfg      Config
+	queue    chan delivery
+
+	// ctx is cancelled by Close to abandon deliveries still retrying.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// delivery is one event on its way to one webhook.
+type delivery struct {
+	webhook models.Webhook
+	event   models.Event
+	body    []byte
+}
+
+func NewDispatcher(webhooks repository.WebhookRepository, cfg Config) *Dispatcher {
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = DefaultConfig.Attempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultConfig.Backoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = max(DefaultConfig.MaxBackoff, cfg.Backoff)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultConfig.Timeout
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultConfig.Workers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultConfig.QueueSize
+	}
+	client := newClient(cfg.AllowPrivateNetworks)
+	if cfg.Client != nil {
+		c := *cfg.Client
+		client = &c
+	}
+	client.CheckRedirect = refuseRedirect
+	cfg.Client = client
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{webhooks: webhooks, cfg: cfg, queue: make(chan delivery, cfg.QueueSize), ctx: ctx, cancel: cancel}
+	d.workers.Add(cfg.Workers)
+	for range cfg.Workers {
+		go d.work()
+	}
+	return d
+}
+
+// Publish queues event for every webhook subscribed to its type and returns
+// without waiting for the deliveries. Events published after Close are
+// dropped.
+func (d *Dispatcher) Publish(ctx context.Context, event models.Event) {
+	webhooks, err := d.webhooks.GetAll()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list webhooks", "event_id", event.ID, "err", err)
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to encode webhook event", "event_id", event.ID, "err", err)
+		return
+	}
+
+	var overflow []models.Webhook
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	for _, webhook := range webhooks {
+		if !webhook.Wants(event.Type) {
+			continue
+		}
+		select {
+		case d.queue <- delivery{webhook: *webhook, event: event, body: body}:
+		default:
+			overflow = append(overflow, *webhook)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, webhook := range overflow {
+		d.deadLetter(webhook, event, 0, errQueueFull)
+	}
+}
+
+var errQueueFull = errors.New("delivery queue full; not sent")
+
+// Close stops accepting events and waits for queued deliveries and those in
+// flight, retries included, until ctx is done. Deliveries still pending then
+// are abandoned and kept as dead letters, so Close must return before the
+// webhook repository is closed.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// work runs deliveries from the queue until Close closes it.
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+	for job := range d.queue {
+		d.deliver(job.webhook, job.event, job.body)
+	}
+}
+
+// deliver sends body to webhook until it is accepted or the attempts run
+// out, then dead-letters the event.
+func (d *Dispatcher) deliver(webhook models.Webhook, event models.Event, body []byte) {
+	backoff := d.cfg.Backoff
+	attempts := 0
+	var err error
+	for attempts < d.cfg.Attempts {
+		if attempts > 0 {
+			timer := time.NewTimer(jitter(backoff))
+			select {
+			case <-timer.C:
+			case <-d.ctx.Done():
+				timer.Stop()
+				err = fmt.Errorf("abandoned at shutdown; last attempt: %w", err)
+				d.deadLetter(webhook, event, attempts, err)
+				return
+			}
+			backoff = min(2*backoff, d.cfg.MaxBackoff)
+		}
+		attempts++
+		if err = d.send(webhook, event, body); err == nil {
+			return
+		}
+	}
+	d.deadLetter(webhook, event, attempts, err)
+}
+
+func (d *Dispatcher) send(webhook models.Webhook, event models.Event, body []byte) error {
+	ctx, cancel := context.WithTimeout(d.ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(EventIDHeader, event.ID)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, time.Now(), body))
+
+	resp, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded %s", webhook.URL, resp.Status)
+	}
+	return nil
+}
+
+func (d *Dispatcher) deadLetter(webhook models.Webhook, event models.Event, attempts int, err error) {
+	letter := models.DeadLetter{
+		ID:        uuid.New().String(),
+		WebhookID: webhook.ID,
+		Event:     event,
+		Attempts:  attempts,
+		LastError: err.Error(),
+		FailedAt:  time.Now().UTC().Round(0),
+	}
+	slog.Warn("webhook delivery failed", "webhook_id", webhook.ID, "event_id", event.ID,
+		"attempts", attempts, "err", err)
+	// A webhook deleted while its event was retrying takes nothing with it.
+	if err := d.webhooks.AddDeadLetter(letter); err != nil && !errors.Is(err, response.ErrWebhookNotFound) {
+		slog.Error("failed to record webhook dead letter", "webhook_id", webhook.ID, "event_id", event.ID, "err", err)
+	}
+}
+
+// jitter adds up to 20% to d so retries of one outage do not arrive in
+// lockstep.
+func jitter(d time.Duration) time.Duration {
+	return d + rand.N(d/5+1)
+}
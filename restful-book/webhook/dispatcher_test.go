@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/domain/models"
+	"github.com/wahonoridhoninggusti/go_learn/restful-book/repository"
+)
+
+func TestPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::1":   true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"0.0.0.0":              false,
+		"100.64.0.1":           false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:10.0.0.1":      false,
+		"64:ff9b::a00:1":       false,
+		"224.0.0.1":            false,
+		"255.255.255.255":      false,
+		"::ffff:93.184.216.34": true,
+		"8.8.8.8":              true,
+		"2001:4860:4860::8888": true,
+	} {
+		if got := publicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+// newTestDispatcher registers a webhook for url and returns a dispatcher
+// for it, closed at the end of the test.
+func newTestDispatcher(t *testing.T, url string, cfg Config) (*Dispatcher, repository.WebhookRepository, string) {
+	t.Helper()
+	repo := repository.NewWebhookRepository()
+	hook := &models.Webhook{ID: "hook", URL: url, Secret: "s"}
+	if err := repo.Create(hook); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Attempts, cfg.Backoff = 1, time.Millisecond
+	d := NewDispatcher(repo, cfg)
+	t.Cleanup(func() { d.Close(context.Background()) })
+	return d, repo, hook.ID
+}
+
+func deadLetters(t *testing.T, d *Dispatcher, repo repository.WebhookRepository, id string) []models.DeadLetter {
+	t.Helper()
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	letters, err := repo.DeadLetters(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return letters
+}
+
+func TestPrivateDestinations(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	defer srv.Close()
+
+	d, repo, id := newTestDispatcher(t, srv.URL, Config{})
+	d.Publish(context.Background(), models.Event{ID: "e1", Type: models.EventBookCreated})
+	letters := deadLetters(t, d, repo, id)
+	if len(letters) != 1 || !strings.Contains(letters[0].LastError, "not a public address") {
+		t.Fatalf("dead letters: %+v", letters)
+	}
+	if hits.Load() != 0 {
+		t.Fatal("a loopback receiver was reached without AllowPrivateNetworks")
+	}
+}
+
+func TestRedirectsNotFollowed(t *testing.T) {
+	var hits atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	for name, cfg := range map[string]Config{
+		"DefaultClient": {AllowPrivateNetworks: true},
+		"CustomClient":  {Client: &http.Client{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d, repo, id := newTestDispatcher(t, redirect.URL, cfg)
+			d.Publish(context.Background(), models.Event{ID: "e1", Type: models.EventBookCreated})
+			letters := deadLetters(t, d, repo, id)
+			if len(letters) != 1 || !strings.Contains(letters[0].LastError, "307") {
+				t.Fatalf("dead letters: %+v", letters)
+			}
+		})
+	}
+	if hits.Load() != 0 {
+		t.Fatal("a redirect was followed")
+	}
+}
+
+func TestQueueBound(t *testing.T) {
+	release := make(chan struct{})
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+	}))
+	defer srv.Close()
+
+	d, repo, id := newTestDispatcher(t, srv.URL, Config{AllowPrivateNetworks: true, Workers: 1, QueueSize: 1})
+	d.Publish(context.Background(), models.Event{ID: "e1", Type: models.EventBookCreated})
+	for hits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The only worker is busy with e1, so e2 waits in the queue and e3
+	// finds it full.
+	d.Publish(context.Background(), models.Event{ID: "e2", Type: models.EventBookCreated})
+	d.Publish(context.Background(), models.Event{ID: "e3", Type: models.EventBookCreated})
+	close(release)
+
+	letters := deadLetters(t, d, repo, id)
+	if len(letters) != 1 || letters[0].Event.ID != "e3" || letters[0].Attempts != 0 {
+		t.Fatalf("dead letters: %+v", letters)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("receiver got %d deliveries, want 2", got)
+	}
+}